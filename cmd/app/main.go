@@ -1,13 +1,22 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/anu-mdl/linker-bruno/internal/modules/mockserver"
+	"github.com/anu-mdl/linker-bruno/internal/modules/recorder"
 	"github.com/anu-mdl/linker-bruno/internal/modules/webui"
+	"github.com/anu-mdl/linker-bruno/internal/shared/certs"
 	"github.com/anu-mdl/linker-bruno/internal/shared/logger"
 	"github.com/anu-mdl/linker-bruno/internal/shared/middleware"
 	"github.com/go-chi/chi/v5"
@@ -20,17 +29,90 @@ func main() {
 	// Parse CLI flags
 	port := flag.Int("port", 8080, "Port to run the server on")
 	dir := flag.String("dir", ".", "Directory containing Bruno collection")
-	env := flag.String("env", "local", "Environment name to load")
+	env := flag.String("env", "local", "Comma-separated environments to load, the first being the default; name=port also serves one on its own port")
 	ui := flag.Bool("ui", false, "Enable web UI for API design")
+	watch := flag.Bool("watch", false, "Reload routes when .bru or environment files change")
+	watchInterval := flag.Duration("watch-interval", time.Second, "Polling interval for --watch")
+	stateful := flag.Bool("stateful", false, "Emulate CRUD resources in memory, seeded from list examples")
+	delay := flag.String("delay", "", "Default response delay, e.g. 200ms, 100ms-500ms, normal(300ms, 50ms) or p50=100ms,p99=1s")
+	proxyTo := flag.String("proxy-to", "", "Forward requests that match no .bru route to this upstream URL")
+	defaultHost := flag.String("default-host", "", "Host whose routes answer requests for hosts no base URL names (default: the only host, if there is one)")
+	useTLS := flag.Bool("tls", false, "Serve HTTPS (with HTTP/2) instead of HTTP")
+	tlsCert := flag.String("tls-cert", "", "Certificate file for --tls (default: generate one signed by a local CA)")
+	tlsKey := flag.String("tls-key", "", "Private key file for --tls-cert")
+	tlsHosts := flag.String("tls-hosts", "", "Comma-separated host names and IPs for the generated certificate (default: localhost,127.0.0.1,::1)")
+	tlsDir := flag.String("tls-dir", "", "Directory for the generated CA and certificate (default: user cache directory)")
+	cors := flag.Bool("cors", false, "Answer CORS preflight requests and add Access-Control-* headers")
+	corsOrigins := flag.String("cors-origins", "", "Comma-separated allowed origins, wildcards allowed (default: any origin, without credentials)")
+	corsMethods := flag.String("cors-methods", "", "Comma-separated methods allowed in preflight responses (default: all supported)")
+	corsHeaders := flag.String("cors-headers", "", "Comma-separated request headers allowed in preflight responses (default: whatever is requested)")
+	corsExpose := flag.String("cors-expose", "", "Comma-separated response headers readable by scripts (default: all)")
+	corsCredentials := flag.Bool("cors-credentials", false, "Allow cookies and authorization headers on cross-origin requests from the --cors-origins")
+	corsMaxAge := flag.Duration("cors-max-age", 10*time.Minute, "How long browsers may cache preflight responses")
+	rateLimit := flag.String("rate-limit", "", "Requests allowed per client across all routes, e.g. 100/1m (default: unlimited)")
+	rateLimitKey := flag.String("rate-limit-key", middleware.RateLimitByIP, "What --rate-limit counts requests by: ip, auth or header:<name>")
+	rateLimitAlgorithm := flag.String("rate-limit-algorithm", middleware.FixedWindow, "Algorithm for --rate-limit: fixed-window or token-bucket")
+	journalSize := flag.Int("journal-size", mockserver.DefaultJournalSize, "Number of requests kept in the request journal (0 disables it)")
+	record := flag.String("record", "", "Record mode: forward all requests to this upstream URL and save the exchanges as .bru examples")
+	recordAllowHeaders := flag.String("record-allow-headers", "", "Comma-separated headers to record (default: all except denied)")
+	recordDenyHeaders := flag.String("record-deny-headers", "", "Comma-separated headers never to record, in addition to credentials")
+	recordRedact := flag.String("record-redact", "", "Comma-separated JSON fields to redact, in addition to passwords and tokens")
 	flag.Parse()
 
+	envNames, envPorts, err := parseEnvironments(*env, *port)
+	if err != nil {
+		log.Fatalf("Invalid --env: %v", err)
+	}
+
 	log.Printf("Starting Bruno Mock Server")
 	log.Printf("Directory: %s", *dir)
-	log.Printf("Environment: %s", *env)
+	log.Printf("Environment: %s", strings.Join(envNames, ", "))
+
+	// Load or generate the TLS certificate
+	var tlsConfig *tls.Config
+	if *useTLS {
+		certDir := *tlsDir
+		if certDir == "" {
+			certDir = certs.DefaultDir(*dir)
+		}
+		tlsConfig, err = certs.TLSConfig(certs.Options{
+			CertFile: *tlsCert,
+			KeyFile:  *tlsKey,
+			Dir:      certDir,
+			Hosts:    splitList(*tlsHosts),
+		})
+		if err != nil {
+			log.Fatalf("Failed to set up TLS: %v", err)
+		}
+		if *tlsCert == "" {
+			log.Printf("Using certificate signed by the local CA %s", filepath.Join(certDir, certs.CAFile))
+		}
+	}
 
 	// Create router
 	r := chi.NewRouter()
 	middleware.SetupDefault(r)
+	if *cors {
+		r.Use(middleware.CORS(middleware.CORSOptions{
+			AllowedOrigins:   splitList(*corsOrigins),
+			AllowedMethods:   splitList(*corsMethods),
+			AllowedHeaders:   splitList(*corsHeaders),
+			ExposedHeaders:   splitList(*corsExpose),
+			AllowCredentials: *corsCredentials,
+			MaxAge:           *corsMaxAge,
+		}, mockserver.AdminPrefix, webui.APIPrefix))
+	}
+
+	// The limiter also counts route and folder limits, so the admin API resets all of them
+	limiter := middleware.NewRateLimiter()
+	if *rateLimit != "" {
+		limit, err := middleware.ParseRateLimit(*rateLimit, *rateLimitKey, *rateLimitAlgorithm)
+		if err != nil {
+			log.Fatalf("Invalid --rate-limit: %v", err)
+		}
+		log.Printf("Rate limit: %s", limit)
+		r.Use(middleware.LimitRate(limiter, limit, mockserver.AdminPrefix))
+	}
 
 	// Initialize Web UI module (if enabled)
 	if *ui {
@@ -43,8 +125,31 @@ func main() {
 		log.Printf("Web UI available at http://localhost:%d/", *port)
 	}
 
+	// In record mode the recorder replaces the mock server
+	if *record != "" {
+		recorderModule, err := recorder.NewModule(*dir, recorder.Options{
+			Upstream:     *record,
+			AllowHeaders: splitList(*recordAllowHeaders),
+			DenyHeaders:  splitList(*recordDenyHeaders),
+			RedactFields: splitList(*recordRedact),
+		})
+		if err != nil {
+			log.Fatalf("Failed to initialize recorder module: %v", err)
+		}
+		recorderModule.RegisterRoutes(r)
+		serve(*port, r, tlsConfig)
+		return
+	}
+
 	// Initialize Mock Server module
-	mockModule, err := mockserver.NewModule(*dir, *env)
+	mockModule, err := mockserver.NewModule(*dir, envNames, mockserver.Options{
+		Stateful:    *stateful,
+		Delay:       *delay,
+		ProxyTo:     *proxyTo,
+		JournalSize: *journalSize,
+		DefaultHost: *defaultHost,
+		RateLimiter: limiter,
+	})
 	if err != nil {
 		log.Fatalf("Failed to initialize mock server module: %v", err)
 	}
@@ -53,19 +158,93 @@ func main() {
 		log.Fatalf("Failed to register mock routes: %v", err)
 	}
 
-	// Add a default 404 handler
-	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusNotFound)
-		w.Header().Set("Content-Type", "application/json")
-		fmt.Fprintf(w, `{"error": "Route not found", "path": "%s", "method": "%s"}`, r.URL.Path, r.Method)
-	})
+	// Serve environments with a port of their own
+	for _, name := range envNames {
+		if envPort, ok := envPorts[name]; ok {
+			log.Printf("Environment %s on port %d", name, envPort)
+			go serve(envPort, mockModule.WithEnvironment(name, r), tlsConfig)
+		}
+	}
+
+	// Reload routes on file changes
+	if *watch {
+		log.Printf("Watching %s for changes every %s", *dir, *watchInterval)
+		go mockModule.Watch(context.Background(), *watchInterval)
+	}
 
 	// Start the server
-	addr := fmt.Sprintf(":%d", *port)
-	log.Printf("Server listening on http://localhost%s", addr)
+	serve(*port, r, tlsConfig)
+}
+
+// serve starts the server on the given port, over HTTPS and HTTP/2 when
+// tlsConfig is set
+func serve(port int, handler http.Handler, tlsConfig *tls.Config) {
+	addr := fmt.Sprintf(":%d", port)
+	server := &http.Server{Addr: addr, Handler: handler, TLSConfig: tlsConfig}
+
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+	log.Printf("Server listening on %s://localhost%s", scheme, addr)
 	log.Printf("Press Ctrl+C to stop")
 
-	if err := http.ListenAndServe(addr, r); err != nil {
+	var err error
+	if tlsConfig != nil {
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseEnvironments parses the --env flag: environment names, each
+// optionally followed by =port to serve it on a port of its own other than mainPort
+func parseEnvironments(value string, mainPort int) ([]string, map[string]int, error) {
+	var names []string
+	ports := make(map[string]int)
+	for _, item := range splitList(value) {
+		name, portValue, hasPort := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, nil, fmt.Errorf("missing environment name in %q", item)
+		}
+		if slices.Contains(names, name) {
+			return nil, nil, fmt.Errorf("environment %q is listed twice", name)
+		}
+		names = append(names, name)
+
+		if hasPort {
+			port, err := strconv.Atoi(strings.TrimSpace(portValue))
+			if err != nil || port <= 0 || port > 65535 {
+				return nil, nil, fmt.Errorf("invalid port %q for environment %q", portValue, name)
+			}
+			for other, otherPort := range ports {
+				if otherPort == port {
+					return nil, nil, fmt.Errorf("environments %q and %q share port %d", other, name, port)
+				}
+			}
+			if port == mainPort {
+				return nil, nil, fmt.Errorf("environment %q cannot use the main port %d", name, port)
+			}
+			ports[name] = port
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil, fmt.Errorf("no environment given")
+	}
+	return names, ports, nil
+}
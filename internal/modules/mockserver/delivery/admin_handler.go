@@ -0,0 +1,419 @@
+package delivery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/anu-mdl/linker-bruno/internal/modules/mockserver/service"
+	"github.com/anu-mdl/linker-bruno/internal/shared/brunoformat"
+	"github.com/anu-mdl/linker-bruno/internal/shared/response"
+	"github.com/go-chi/chi/v5"
+)
+
+// AdminPrefix is the path prefix of all admin endpoints
+const AdminPrefix = "/__admin"
+
+// AdminHandler exposes runtime controls of the mock server as a JSON API
+type AdminHandler struct {
+	service *service.MockService
+}
+
+// NewAdminHandler creates a new AdminHandler
+func NewAdminHandler(service *service.MockService) *AdminHandler {
+	return &AdminHandler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers all admin routes
+func (h *AdminHandler) RegisterRoutes(r chi.Router) {
+	r.Route(AdminPrefix, func(r chi.Router) {
+		r.Get("/store", h.HandleGetStore)
+		r.Post("/store/reset", h.HandleResetStore)
+
+		r.Get("/scenarios", h.HandleGetScenarios)
+		r.Put("/scenarios/active", h.HandleSetActiveScenario)
+		r.Delete("/scenarios/active", h.HandleClearActiveScenario)
+		r.Post("/scenarios/reset", h.HandleResetScenarios)
+
+		r.Get("/faults", h.HandleGetFaults)
+		r.Put("/faults", h.HandleSetFault)
+		r.Delete("/faults", h.HandleClearFaults)
+		r.Post("/faults/reset", h.HandleResetFaultCounters)
+
+		r.Get("/rate-limits", h.HandleGetRateLimits)
+		r.Post("/rate-limits/reset", h.HandleResetRateLimits)
+
+		r.Get("/stubs", h.HandleListStubs)
+		r.Post("/stubs", h.HandleCreateStub)
+		r.Post("/stubs/reset", h.HandleResetStubs)
+		r.Get("/stubs/{id}", h.HandleGetStub)
+		r.Put("/stubs/{id}", h.HandlePutStub)
+		r.Delete("/stubs/{id}", h.HandleDeleteStub)
+
+		r.Get("/requests", h.HandleListRequests)
+		r.Delete("/requests", h.HandleClearRequests)
+		r.Get("/requests/count", h.HandleCountRequests)
+		r.Post("/requests/verify", h.HandleVerifyRequests)
+	})
+}
+
+// HandleGetStore returns a snapshot of all stateful resources
+func (h *AdminHandler) HandleGetStore(w http.ResponseWriter, r *http.Request) {
+	store := h.service.Store()
+	if store == nil {
+		response.WriteBadRequest(w, "stateful mode is disabled; start the server with --stateful")
+		return
+	}
+	response.WriteSuccess(w, store.Snapshot())
+}
+
+// HandleResetStore restores all stateful resources to their seed data
+func (h *AdminHandler) HandleResetStore(w http.ResponseWriter, r *http.Request) {
+	store := h.service.Store()
+	if store == nil {
+		response.WriteBadRequest(w, "stateful mode is disabled; start the server with --stateful")
+		return
+	}
+	store.Reset()
+	response.WriteSuccess(w, store.Snapshot())
+}
+
+// scenarioInput is the body of PUT /__admin/scenarios/active
+type scenarioInput struct {
+	Name string `json:"name"`
+}
+
+// HandleGetScenarios returns the active scenario, known scenarios and sequence counters
+func (h *AdminHandler) HandleGetScenarios(w http.ResponseWriter, r *http.Request) {
+	h.writeScenarios(w)
+}
+
+// HandleSetActiveScenario switches the collection-wide scenario
+func (h *AdminHandler) HandleSetActiveScenario(w http.ResponseWriter, r *http.Request) {
+	var input scenarioInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Name == "" {
+		response.WriteBadRequest(w, `body must be {"name": "<scenario>"}`)
+		return
+	}
+	h.service.Scenarios().SetActive(input.Name)
+	h.writeScenarios(w)
+}
+
+// HandleClearActiveScenario returns to the default (no scenario) responses
+func (h *AdminHandler) HandleClearActiveScenario(w http.ResponseWriter, r *http.Request) {
+	h.service.Scenarios().SetActive("")
+	h.writeScenarios(w)
+}
+
+// HandleResetScenarios restarts response sequences, optionally for ?scenario=<name> only
+func (h *AdminHandler) HandleResetScenarios(w http.ResponseWriter, r *http.Request) {
+	h.service.Scenarios().ResetCounters(r.URL.Query().Get("scenario"))
+	h.writeScenarios(w)
+}
+
+// writeScenarios writes the current scenario state
+func (h *AdminHandler) writeScenarios(w http.ResponseWriter) {
+	scenarios := h.service.Scenarios()
+	response.WriteSuccess(w, map[string]interface{}{
+		"active":   scenarios.Active(),
+		"known":    scenarios.Known(),
+		"counters": scenarios.Counters(),
+	})
+}
+
+// faultInput is the body of PUT /__admin/faults; an empty route sets the global rule
+type faultInput struct {
+	Route string `json:"route"`
+	service.FaultConfig
+}
+
+// HandleGetFaults returns the fault rules and how often each fault was injected
+func (h *AdminHandler) HandleGetFaults(w http.ResponseWriter, r *http.Request) {
+	h.writeFaults(w)
+}
+
+// HandleSetFault installs a fault rule for one route ("GET /users/{id}") or globally
+func (h *AdminHandler) HandleSetFault(w http.ResponseWriter, r *http.Request) {
+	var input faultInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		response.WriteBadRequest(w, "invalid fault rule: "+err.Error())
+		return
+	}
+	if err := h.service.Faults().Set(input.Route, input.FaultConfig); err != nil {
+		response.WriteBadRequest(w, err.Error())
+		return
+	}
+	h.writeFaults(w)
+}
+
+// HandleClearFaults removes the rule for ?route=<route>, or every rule
+func (h *AdminHandler) HandleClearFaults(w http.ResponseWriter, r *http.Request) {
+	h.service.Faults().Clear(r.URL.Query().Get("route"))
+	h.writeFaults(w)
+}
+
+// HandleResetFaultCounters sets all fault counters back to zero
+func (h *AdminHandler) HandleResetFaultCounters(w http.ResponseWriter, r *http.Request) {
+	h.service.Faults().ResetCounters()
+	h.writeFaults(w)
+}
+
+// HandleGetRateLimits returns the remaining allowance of every client per rate limit
+func (h *AdminHandler) HandleGetRateLimits(w http.ResponseWriter, r *http.Request) {
+	response.WriteSuccess(w, h.service.RateLimiter().Buckets())
+}
+
+// HandleResetRateLimits restores every client's full allowance
+func (h *AdminHandler) HandleResetRateLimits(w http.ResponseWriter, r *http.Request) {
+	h.service.RateLimiter().Reset()
+	response.WriteSuccess(w, h.service.RateLimiter().Buckets())
+}
+
+// writeFaults writes the current fault rules and counters
+func (h *AdminHandler) writeFaults(w http.ResponseWriter) {
+	faults := h.service.Faults()
+	response.WriteSuccess(w, map[string]interface{}{
+		"global":   faults.Global(),
+		"routes":   faults.Routes(),
+		"counters": faults.Counters(),
+	})
+}
+
+// stubInput is the body of POST /__admin/stubs and PUT /__admin/stubs/{id}:
+// a brunoformat.BrunoRequest in JSON, with a single example as a shorthand
+// for examples, and whether to write the stub to a .bru file
+type stubInput struct {
+	brunoformat.BrunoRequest
+	ID      string                    `json:"id"`
+	Example *brunoformat.ExampleBlock `json:"example"`
+	Persist bool                      `json:"persist"`
+}
+
+// HandleListStubs returns all runtime stubs
+func (h *AdminHandler) HandleListStubs(w http.ResponseWriter, r *http.Request) {
+	response.WriteSuccess(w, h.service.Stubs())
+}
+
+// HandleGetStub returns a single stub
+func (h *AdminHandler) HandleGetStub(w http.ResponseWriter, r *http.Request) {
+	stub, ok := h.service.Stub(chi.URLParam(r, "id"))
+	if !ok {
+		response.WriteNotFound(w, "stub not found")
+		return
+	}
+	response.WriteSuccess(w, stub)
+}
+
+// HandleCreateStub adds a stub; an id in the body replaces the stub with that id
+func (h *AdminHandler) HandleCreateStub(w http.ResponseWriter, r *http.Request) {
+	h.putStub(w, r, "")
+}
+
+// HandlePutStub adds or replaces the stub with the id from the path
+func (h *AdminHandler) HandlePutStub(w http.ResponseWriter, r *http.Request) {
+	h.putStub(w, r, chi.URLParam(r, "id"))
+}
+
+// putStub decodes a stub and installs it; id from the path wins over the body
+func (h *AdminHandler) putStub(w http.ResponseWriter, r *http.Request, id string) {
+	var input stubInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		response.WriteBadRequest(w, "invalid stub: "+err.Error())
+		return
+	}
+	if id == "" {
+		id = input.ID
+	}
+	if input.Example != nil {
+		input.Examples = append(input.Examples, *input.Example)
+	}
+
+	stub, created, err := h.service.PutStub(id, &input.BrunoRequest, input.Persist)
+	if errors.Is(err, service.ErrStubFileExists) {
+		response.WriteError(w, http.StatusConflict, "CONFLICT", err.Error())
+		return
+	}
+	if err != nil {
+		response.WriteBadRequest(w, err.Error())
+		return
+	}
+	if created {
+		response.WriteJSON(w, http.StatusCreated, response.Success(stub))
+		return
+	}
+	response.WriteSuccess(w, stub)
+}
+
+// HandleDeleteStub removes a stub
+func (h *AdminHandler) HandleDeleteStub(w http.ResponseWriter, r *http.Request) {
+	if !h.service.DeleteStub(chi.URLParam(r, "id")) {
+		response.WriteNotFound(w, "stub not found")
+		return
+	}
+	response.WriteSuccess(w, h.service.Stubs())
+}
+
+// HandleResetStubs removes all stubs
+func (h *AdminHandler) HandleResetStubs(w http.ResponseWriter, r *http.Request) {
+	h.service.ResetStubs()
+	response.WriteSuccess(w, h.service.Stubs())
+}
+
+// journal returns the request journal, writing an error if it is disabled
+func (h *AdminHandler) journal(w http.ResponseWriter) *service.Journal {
+	journal := h.service.Journal()
+	if journal == nil {
+		response.WriteBadRequest(w, "the request journal is disabled; start the server with a positive --journal-size")
+	}
+	return journal
+}
+
+// journalFilter reads a journal filter from the query string:
+// method, path, route, file and status, plus header.<name>, query.<name>
+// and body.<json path> for individual values
+func journalFilter(r *http.Request) (service.JournalFilter, error) {
+	var filter service.JournalFilter
+	for key, values := range r.URL.Query() {
+		value := values[0]
+		switch {
+		case key == "method":
+			filter.Method = value
+		case key == "path":
+			filter.Path = value
+		case key == "route":
+			filter.Route = value
+		case key == "file":
+			filter.File = value
+		case key == "status":
+			status, err := strconv.Atoi(value)
+			if err != nil {
+				return filter, fmt.Errorf("invalid status %q", value)
+			}
+			filter.Status = status
+		case strings.HasPrefix(key, "header."):
+			if filter.Headers == nil {
+				filter.Headers = make(map[string]string)
+			}
+			filter.Headers[strings.TrimPrefix(key, "header.")] = value
+		case strings.HasPrefix(key, "query."):
+			if filter.Query == nil {
+				filter.Query = make(map[string]string)
+			}
+			filter.Query[strings.TrimPrefix(key, "query.")] = value
+		case strings.HasPrefix(key, "body."):
+			if filter.Body == nil {
+				filter.Body = make(map[string]interface{})
+			}
+			filter.Body[strings.TrimPrefix(key, "body.")] = value
+		default:
+			return filter, fmt.Errorf("unknown filter %q", key)
+		}
+	}
+	return filter, nil
+}
+
+// HandleListRequests returns the journaled requests matching the query filter, oldest first
+func (h *AdminHandler) HandleListRequests(w http.ResponseWriter, r *http.Request) {
+	journal := h.journal(w)
+	if journal == nil {
+		return
+	}
+	filter, err := journalFilter(r)
+	if err != nil {
+		response.WriteBadRequest(w, err.Error())
+		return
+	}
+	requests := journal.Entries(filter)
+	response.WriteSuccess(w, map[string]interface{}{
+		"count":    len(requests),
+		"requests": requests,
+	})
+}
+
+// HandleCountRequests returns how many journaled requests match the query filter
+func (h *AdminHandler) HandleCountRequests(w http.ResponseWriter, r *http.Request) {
+	journal := h.journal(w)
+	if journal == nil {
+		return
+	}
+	filter, err := journalFilter(r)
+	if err != nil {
+		response.WriteBadRequest(w, err.Error())
+		return
+	}
+	response.WriteSuccess(w, map[string]int{"count": journal.Count(filter)})
+}
+
+// HandleClearRequests empties the request journal
+func (h *AdminHandler) HandleClearRequests(w http.ResponseWriter, r *http.Request) {
+	journal := h.journal(w)
+	if journal == nil {
+		return
+	}
+	journal.Clear()
+	response.WriteSuccess(w, map[string]int{"count": 0})
+}
+
+// verifyInput is the body of POST /__admin/requests/verify. Without count,
+// atLeast or atMost, at least one matching request is expected.
+type verifyInput struct {
+	service.JournalFilter
+	Count   *int `json:"count"`
+	AtLeast *int `json:"atLeast"`
+	AtMost  *int `json:"atMost"`
+}
+
+// expectation describes the expected number of matches and checks it
+func (in verifyInput) expectation(count int) (string, bool) {
+	switch {
+	case in.Count != nil:
+		return fmt.Sprintf("exactly %d", *in.Count), count == *in.Count
+	case in.AtLeast != nil && in.AtMost != nil:
+		return fmt.Sprintf("between %d and %d", *in.AtLeast, *in.AtMost), count >= *in.AtLeast && count <= *in.AtMost
+	case in.AtMost != nil:
+		return fmt.Sprintf("at most %d", *in.AtMost), count <= *in.AtMost
+	case in.AtLeast != nil:
+		return fmt.Sprintf("at least %d", *in.AtLeast), count >= *in.AtLeast
+	default:
+		return "at least 1", count >= 1
+	}
+}
+
+// HandleVerifyRequests checks how often requests matching a filter were
+// received. A failed verification is answered with 409 so test clients can
+// assert on the status code alone.
+func (h *AdminHandler) HandleVerifyRequests(w http.ResponseWriter, r *http.Request) {
+	journal := h.journal(w)
+	if journal == nil {
+		return
+	}
+
+	var input verifyInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		response.WriteBadRequest(w, "invalid verification: "+err.Error())
+		return
+	}
+
+	requests := journal.Entries(input.JournalFilter)
+	expected, ok := input.expectation(len(requests))
+	result := map[string]interface{}{
+		"verified": ok,
+		"expected": expected,
+		"count":    len(requests),
+		"requests": requests,
+	}
+	if ok {
+		response.WriteSuccess(w, result)
+		return
+	}
+
+	resp := response.Error("VERIFICATION_FAILED",
+		fmt.Sprintf("expected %s matching requests, got %d", expected, len(requests)))
+	resp.Data = result
+	response.WriteJSON(w, http.StatusConflict, resp)
+}
@@ -0,0 +1,210 @@
+package latency
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Distribution produces response delays
+type Distribution interface {
+	// Sample returns the delay for one response
+	Sample() time.Duration
+	// String returns the spec the distribution was parsed from
+	String() string
+}
+
+var (
+	normalRe     = regexp.MustCompile(`^normal\(\s*([^,\s]+)\s*,\s*([^)\s]+)\s*\)$`)
+	percentileRe = regexp.MustCompile(`^p(\d+(?:\.\d+)?)\s*=\s*(\S+)$`)
+)
+
+// Parse reads a delay spec:
+//
+//	250ms                        fixed delay
+//	100ms-500ms                  uniform jitter between min and max
+//	normal(300ms, 50ms)          normal distribution with mean and standard deviation
+//	p50=100ms, p95=400ms, p99=1s piecewise-linear between percentiles
+//
+// Bare numbers are milliseconds. An empty spec or "0" returns nil (no delay).
+func Parse(spec string) (Distribution, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "0" {
+		return nil, nil
+	}
+
+	if match := normalRe.FindStringSubmatch(spec); match != nil {
+		mean, err := parseDuration(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid delay %q: %w", spec, err)
+		}
+		stddev, err := parseDuration(match[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid delay %q: %w", spec, err)
+		}
+		return normal{spec: spec, mean: mean, stddev: stddev}, nil
+	}
+
+	if strings.HasPrefix(spec, "p") {
+		return parsePercentiles(spec)
+	}
+
+	if low, high, ok := strings.Cut(spec, "-"); ok {
+		min, err := parseDuration(low)
+		if err != nil {
+			return nil, fmt.Errorf("invalid delay %q: %w", spec, err)
+		}
+		max, err := parseDuration(high)
+		if err != nil {
+			return nil, fmt.Errorf("invalid delay %q: %w", spec, err)
+		}
+		if max < min {
+			return nil, fmt.Errorf("invalid delay %q: max is below min", spec)
+		}
+		return uniform{spec: spec, min: min, max: max}, nil
+	}
+
+	d, err := parseDuration(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid delay %q: %w", spec, err)
+	}
+	return fixed{spec: spec, d: d}, nil
+}
+
+// Sleep waits for a delay sampled from dist, returning early with the
+// context's error if the request is cancelled. A nil dist returns immediately.
+func Sleep(ctx context.Context, dist Distribution) error {
+	if dist == nil {
+		return nil
+	}
+	d := dist.Sample()
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// parseDuration accepts Go durations and bare millisecond counts
+func parseDuration(s string) (time.Duration, error) {
+	if ms, err := strconv.ParseFloat(s, 64); err == nil {
+		if ms < 0 {
+			return 0, fmt.Errorf("negative duration %s", s)
+		}
+		return time.Duration(ms * float64(time.Millisecond)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration %s", s)
+	}
+	return d, nil
+}
+
+// fixed always returns the same delay
+type fixed struct {
+	spec string
+	d    time.Duration
+}
+
+func (f fixed) Sample() time.Duration { return f.d }
+func (f fixed) String() string        { return f.spec }
+
+// uniform returns delays evenly spread between min and max
+type uniform struct {
+	spec     string
+	min, max time.Duration
+}
+
+func (u uniform) Sample() time.Duration {
+	if u.max == u.min {
+		return u.min
+	}
+	return u.min + time.Duration(rand.Int64N(int64(u.max-u.min)+1))
+}
+func (u uniform) String() string { return u.spec }
+
+// normal returns normally distributed delays, clamped at zero
+type normal struct {
+	spec         string
+	mean, stddev time.Duration
+}
+
+func (n normal) Sample() time.Duration {
+	d := time.Duration(float64(n.mean) + rand.NormFloat64()*float64(n.stddev))
+	return max(d, 0)
+}
+func (n normal) String() string { return n.spec }
+
+// percentilePoint is a known delay at a percentile
+type percentilePoint struct {
+	p float64
+	d time.Duration
+}
+
+// percentiles interpolates linearly between known percentiles. Below the
+// lowest given percentile delays ramp up from zero; above the highest they
+// stay at its value.
+type percentiles struct {
+	spec   string
+	points []percentilePoint
+}
+
+// parsePercentiles reads "p50=100ms, p95=400ms, p99=1s"
+func parsePercentiles(spec string) (Distribution, error) {
+	dist := percentiles{spec: spec}
+
+	for _, field := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == ' ' }) {
+		match := percentileRe.FindStringSubmatch(field)
+		if match == nil {
+			return nil, fmt.Errorf("invalid delay %q: expected entries like p95=400ms", spec)
+		}
+		p, _ := strconv.ParseFloat(match[1], 64)
+		if p <= 0 || p > 100 {
+			return nil, fmt.Errorf("invalid delay %q: percentile p%s out of range", spec, match[1])
+		}
+		d, err := parseDuration(match[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid delay %q: %w", spec, err)
+		}
+		dist.points = append(dist.points, percentilePoint{p: p, d: d})
+	}
+
+	sort.Slice(dist.points, func(i, j int) bool { return dist.points[i].p < dist.points[j].p })
+	for i := 1; i < len(dist.points); i++ {
+		if dist.points[i].d < dist.points[i-1].d {
+			return nil, fmt.Errorf("invalid delay %q: delays must grow with the percentile", spec)
+		}
+	}
+	return dist, nil
+}
+
+func (pc percentiles) Sample() time.Duration {
+	u := rand.Float64() * 100
+	lower := percentilePoint{}
+	for _, upper := range pc.points {
+		if u <= upper.p {
+			ratio := (u - lower.p) / (upper.p - lower.p)
+			return lower.d + time.Duration(math.Round(ratio*float64(upper.d-lower.d)))
+		}
+		lower = upper
+	}
+	return lower.d
+}
+func (pc percentiles) String() string { return pc.spec }
@@ -1,53 +1,148 @@
 package mockserver
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/anu-mdl/linker-bruno/internal/modules/mockserver/delivery"
+	"github.com/anu-mdl/linker-bruno/internal/modules/mockserver/latency"
 	"github.com/anu-mdl/linker-bruno/internal/modules/mockserver/repository"
 	"github.com/anu-mdl/linker-bruno/internal/modules/mockserver/service"
+	webuirepo "github.com/anu-mdl/linker-bruno/internal/modules/webui/repository"
 	"github.com/anu-mdl/linker-bruno/internal/shared/brunoformat"
+	"github.com/anu-mdl/linker-bruno/internal/shared/middleware"
+	"github.com/anu-mdl/linker-bruno/internal/shared/response"
 	"github.com/anu-mdl/linker-bruno/internal/shared/urlutil"
 	"github.com/go-chi/chi/v5"
 )
 
+// DefaultJournalSize is the default number of requests kept in the request journal
+const DefaultJournalSize = service.DefaultJournalSize
+
+// EnvHeader selects the environment for a single request when several are loaded
+const EnvHeader = "X-Bruno-Env"
+
+// AdminPrefix is the path prefix of the admin API
+const AdminPrefix = delivery.AdminPrefix
+
+// Options configures optional mock server features
+type Options struct {
+	// Stateful emulates CRUD resources in memory, seeded from list examples
+	Stateful bool
+	// Delay is the latency spec for routes that do not set their own
+	Delay string
+	// ProxyTo is the upstream URL for requests that match no route
+	ProxyTo string
+	// JournalSize is the number of requests kept for the admin API; 0 disables the journal
+	JournalSize int
+	// DefaultHost serves requests for hosts no route's base URL names
+	DefaultHost string
+	// RateLimiter counts requests against route and folder rate limits;
+	// share it with middleware.LimitRate so the admin API resets both
+	RateLimiter *middleware.RateLimiter
+}
+
 // Module represents the mock server module with all its dependencies
 type Module struct {
-	baseDir  string
-	envName  string
-	service  *service.MockService
-	repo     *repository.BruRepository
-	requests []*brunoformat.BrunoRequest
-	envVars  map[string]string
+	baseDir      string
+	envNames     []string // the first is the default environment
+	service      *service.MockService
+	repo         *repository.BruRepository
+	adminHandler *delivery.AdminHandler
+
+	// tables are swapped atomically on reload; requests in flight keep
+	// using the table they started with
+	tables   atomic.Pointer[routeTables]
+	reloadMu sync.Mutex
 }
 
-// NewModule creates and initializes a new mock server module
-func NewModule(baseDir, envName string) (*Module, error) {
+// routeTable is an immutable snapshot of the mock routes of one environment
+type routeTable struct {
+	handler http.Handler
+	routes  []service.RouteInfo
+	envVars map[string]string
+}
+
+// routeTables holds a route table per environment name
+type routeTables map[string]*routeTable
+
+// NewModule creates and initializes a new mock server module serving the
+// given environments; the first one is the default
+func NewModule(baseDir string, envNames []string, opts Options) (*Module, error) {
+	if len(envNames) == 0 {
+		return nil, fmt.Errorf("at least one environment is required")
+	}
+
 	// Initialize dependencies
 	converter := urlutil.NewConverter()
 
 	// Create repository
 	repo := repository.NewBruRepository()
 
-	// Load environment variables
-	envVars, err := repo.LoadEnvironment(envName, baseDir)
+	delay, err := latency.Parse(opts.Delay)
 	if err != nil {
-		// Non-fatal error, continue with empty env vars
-		log.Printf("Warning: failed to load environment: %v", err)
-		envVars = make(map[string]string)
-	} else {
-		log.Printf("Loaded %d environment variables", len(envVars))
+		return nil, err
 	}
 
-	// Load all .bru requests
-	log.Printf("Scanning for .bru files...")
-	requests, err := repo.LoadAllRequests(baseDir)
+	var proxyTo *url.URL
+	if opts.ProxyTo != "" {
+		proxyTo, err = url.Parse(opts.ProxyTo)
+		if err != nil || proxyTo.Scheme == "" || proxyTo.Host == "" {
+			return nil, fmt.Errorf("invalid proxy upstream %q: expected an absolute URL like http://localhost:3000", opts.ProxyTo)
+		}
+	}
+
+	// Create service
+	mockService := service.NewMockService(converter, service.Options{
+		Stateful:    opts.Stateful,
+		Delay:       delay,
+		ProxyTo:     proxyTo,
+		JournalSize: opts.JournalSize,
+		DefaultHost: opts.DefaultHost,
+		RateLimiter: opts.RateLimiter,
+		BaseDir:     baseDir,
+		Files:       webuirepo.NewFileRepository(brunoformat.NewSerializer()),
+	})
+
+	// Create handlers
+	adminHandler := delivery.NewAdminHandler(mockService)
+
+	m := &Module{
+		baseDir:      baseDir,
+		envNames:     envNames,
+		service:      mockService,
+		repo:         repo,
+		adminHandler: adminHandler,
+	}
+
+	// Build the initial route tables
+	tables, err := m.load()
 	if err != nil {
 		return nil, err
 	}
 
-	log.Printf("Found %d Bruno requests", len(requests))
+	for _, envName := range envNames {
+		for _, route := range tables[envName].routes {
+			if route.Proxied {
+				log.Printf("%sProxied: %s -> %s (from %s)", m.label(envName), route.Key(), opts.ProxyTo, route.FilePath)
+				continue
+			}
+			log.Printf("%sRegistered: %s (from %s)", m.label(envName), route.Key(), route.FilePath)
+		}
+	}
+	if proxyTo != nil {
+		log.Printf("Forwarding unmatched requests to %s", proxyTo)
+	}
 
-	if len(requests) == 0 {
+	if len(tables[envNames[0]].routes) == 0 {
 		log.Println("Warning: No valid .bru files found with response blocks")
 		log.Println("Make sure your .bru files contain:")
 		log.Println("  1. An HTTP method block (get, post, put, delete, patch)")
@@ -55,20 +150,194 @@ func NewModule(baseDir, envName string) (*Module, error) {
 		log.Println("  3. An example block with mock response data")
 	}
 
-	// Create service
-	mockService := service.NewMockService(converter)
-
-	return &Module{
-		baseDir:  baseDir,
-		envName:  envName,
-		service:  mockService,
-		repo:     repo,
-		requests: requests,
-		envVars:  envVars,
-	}, nil
+	m.tables.Store(&tables)
+	return m, nil
+}
+
+// label prefixes log lines with the environment when several are loaded
+func (m *Module) label(envName string) string {
+	if len(m.envNames) == 1 {
+		return ""
+	}
+	return "[" + envName + "] "
 }
 
-// RegisterRoutes registers all mock routes on the provided router
+// RegisterRoutes registers the admin API and installs the mock server as the
+// fallback handler of the provided router, so routes defined directly on the
+// router (e.g. the web UI) take precedence
 func (m *Module) RegisterRoutes(router chi.Router) error {
-	return m.service.RegisterRoutes(router.(*chi.Mux), m.requests, m.envVars)
+	m.adminHandler.RegisterRoutes(router)
+	router.NotFound(m.ServeHTTP)
+	router.MethodNotAllowed(m.ServeHTTP)
+	return nil
+}
+
+// envKey carries the environment chosen by the port a request arrived on
+type envKey struct{}
+
+// WithEnvironment wraps a handler so that requests are served from the given
+// environment instead of the default one, e.g. for a port per environment.
+// The EnvHeader of a request still takes precedence.
+func (m *Module) WithEnvironment(envName string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), envKey{}, envName)))
+	})
+}
+
+// ServeHTTP dispatches a request to the current route table of its environment
+func (m *Module) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	envName := m.envNames[0]
+	if name, ok := r.Context().Value(envKey{}).(string); ok {
+		envName = name
+	}
+	if name := r.Header.Get(EnvHeader); name != "" {
+		envName = name
+	}
+
+	table, ok := (*m.tables.Load())[envName]
+	if !ok {
+		response.WriteBadRequest(w, fmt.Sprintf("unknown environment %q (loaded: %s)", envName, strings.Join(m.envNames, ", ")))
+		return
+	}
+	table.handler.ServeHTTP(w, r)
+}
+
+// Reload rebuilds the route table from disk and swaps it in.
+// On failure the current table stays active and the error is returned.
+func (m *Module) Reload() error {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	tables, err := m.load()
+	if err != nil {
+		return err
+	}
+
+	old := m.tables.Swap(&tables)
+	m.logChanges(*old, tables)
+	return nil
+}
+
+// Watch polls the collection directory and environment files every interval
+// and reloads the routes when anything changes. It returns when ctx is done.
+func (m *Module) Watch(ctx context.Context, interval time.Duration) {
+	last, err := m.repo.Snapshot(m.baseDir, m.envNames)
+	if err != nil {
+		log.Printf("Warning: watcher failed to scan %s: %v", m.baseDir, err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current, err := m.repo.Snapshot(m.baseDir, m.envNames)
+		if err != nil {
+			log.Printf("Warning: watcher failed to scan %s: %v", m.baseDir, err)
+			continue
+		}
+		if maps.Equal(last, current) {
+			continue
+		}
+		last = current
+
+		log.Printf("Change detected in %s, reloading routes", m.baseDir)
+		if err := m.Reload(); err != nil {
+			log.Printf("Reload failed, keeping previous routes: %v", err)
+		}
+	}
+}
+
+// load reads the environments and all requests and builds a route table per environment
+func (m *Module) load() (routeTables, error) {
+	// Load all .bru requests
+	log.Printf("Scanning for .bru files...")
+	requests, err := m.repo.LoadAllRequests(m.baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Found %d Bruno requests", len(requests))
+
+	tables := make(routeTables, len(m.envNames))
+	envs := make([]service.Environment, 0, len(m.envNames))
+	for _, envName := range m.envNames {
+		// Load environment variables
+		envVars, err := m.repo.LoadEnvironment(envName, m.baseDir)
+		if err != nil {
+			// Non-fatal error, continue with empty env vars
+			log.Printf("%sWarning: failed to load environment: %v", m.label(envName), err)
+			envVars = make(map[string]string)
+		} else {
+			log.Printf("%sLoaded %d environment variables", m.label(envName), len(envVars))
+		}
+
+		router, routes, err := m.service.BuildRouter(requests, envName, envVars)
+		if err != nil {
+			if len(m.envNames) > 1 {
+				return nil, fmt.Errorf("environment %q: %w", envName, err)
+			}
+			return nil, err
+		}
+
+		hosts := make([]string, len(routes))
+		for i, route := range routes {
+			hosts[i] = route.Host
+		}
+
+		tables[envName] = &routeTable{handler: router, routes: routes, envVars: envVars}
+		envs = append(envs, service.Environment{Name: envName, Vars: envVars, Hosts: hosts})
+	}
+
+	m.service.SetStubEnvironments(envs)
+	return tables, nil
+}
+
+// logChanges logs routes added, removed or changed between two sets of tables
+func (m *Module) logChanges(old, current routeTables) {
+	for _, envName := range m.envNames {
+		m.logTableChanges(envName, old[envName], current[envName])
+	}
+}
+
+// logTableChanges logs routes added, removed or changed between two tables of an environment
+func (m *Module) logTableChanges(envName string, old, current *routeTable) {
+	label := m.label(envName)
+	before := make(map[string]service.RouteInfo, len(old.routes))
+	for _, route := range old.routes {
+		before[route.Key()] = route
+	}
+
+	changes := 0
+	for _, route := range current.routes {
+		previous, existed := before[route.Key()]
+		delete(before, route.Key())
+
+		switch {
+		case !existed:
+			log.Printf("%sRoute added: %s (from %s)", label, route.Key(), route.FilePath)
+		case previous.Signature != route.Signature:
+			log.Printf("%sRoute changed: %s (from %s)", label, route.Key(), route.FilePath)
+		default:
+			continue
+		}
+		changes++
+	}
+
+	for _, route := range before {
+		log.Printf("%sRoute removed: %s (from %s)", label, route.Key(), route.FilePath)
+		changes++
+	}
+
+	if !maps.Equal(old.envVars, current.envVars) {
+		log.Printf("%sEnvironment %q changed", label, envName)
+		changes++
+	}
+
+	log.Printf("%sReloaded %d routes (%d changes)", label, len(current.routes), changes)
 }
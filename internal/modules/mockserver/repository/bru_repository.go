@@ -1,12 +1,13 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
+	"time"
 
 	"github.com/anu-mdl/linker-bruno/internal/shared/brunoformat"
 )
@@ -19,113 +20,178 @@ func NewBruRepository() *BruRepository {
 	return &BruRepository{}
 }
 
-// LoadAllRequests recursively scans a directory for .bru files and parses them
-func (r *BruRepository) LoadAllRequests(baseDir string) ([]*brunoformat.BrunoRequest, error) {
-	var requests []*brunoformat.BrunoRequest
+// FileStamp identifies a version of a file by modification time and size
+type FileStamp struct {
+	ModTime time.Time
+	Size    int64
+}
 
-	// Walk the directory tree
-	err := filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			log.Printf("Warning: failed to access path %s: %v", path, err)
-			return nil // Continue walking
-		}
+// Names of the .bru files that configure a collection or folder rather than define a request
+const (
+	collectionFile = "collection.bru"
+	folderFile     = "folder.bru"
+)
 
-		// Skip directories
-		if info.IsDir() {
-			// Skip environments directory
-			if info.Name() == "environments" {
-				return filepath.SkipDir
+// LoadAllRequests recursively scans a directory for .bru files and parses them.
+// Mock settings from folder.bru files are inherited by the requests below them.
+func (r *BruRepository) LoadAllRequests(baseDir string) ([]*brunoformat.BrunoRequest, error) {
+	var requests []*brunoformat.BrunoRequest
+	var parseErrs []error
+	folders := make(map[string]brunoformat.MockSettings)
+
+	err := r.walkBruFiles(baseDir, func(path string, info os.FileInfo) {
+		switch info.Name() {
+		case collectionFile:
+			return
+		case folderFile:
+			folder, err := brunoformat.ParseFolderFile(path)
+			if err != nil {
+				parseErrs = append(parseErrs, err)
+				return
 			}
-			return nil
-		}
-
-		// Only process .bru files
-		if !strings.HasSuffix(info.Name(), ".bru") {
-			return nil
-		}
-
-		// Skip collection.bru file (collection-level config)
-		if info.Name() == "collection.bru" {
-			return nil
+			if folder.Mock.RateLimit != "" {
+				folder.Mock.RateLimitFolder = filepath.Dir(path)
+			}
+			folders[filepath.Dir(path)] = folder.Mock
+			return
 		}
 
 		// Parse the .bru file
 		req, err := brunoformat.ParseBrunoFile(path)
 		if err != nil {
-			log.Printf("Warning: failed to parse %s: %v", path, err)
-			return nil // Continue walking
+			// Collect the error and keep walking so all broken files are reported at once
+			parseErrs = append(parseErrs, err)
+			return
 		}
 
 		// Only include requests with a valid HTTP method
 		if req.Method == "" {
 			log.Printf("Warning: skipping %s - no HTTP method found", path)
-			return nil
+			return
 		}
 
 		// Only include requests with a URL
 		if req.URL == "" {
 			log.Printf("Warning: skipping %s - no URL found", path)
-			return nil
+			return
 		}
 
 		// Note: Parser now auto-generates default example blocks if missing,
 		// so all valid requests will have a mock response
 
 		requests = append(requests, req)
-		return nil
 	})
-
 	if err != nil {
-		return nil, fmt.Errorf("failed to walk directory %s: %w", baseDir, err)
+		return nil, err
+	}
+
+	if len(parseErrs) > 0 {
+		return nil, fmt.Errorf("failed to parse collection: %w", errors.Join(parseErrs...))
+	}
+
+	for _, req := range requests {
+		req.FolderMock = r.folderSettings(filepath.Dir(req.FilePath), baseDir, folders)
 	}
 
 	return requests, nil
 }
 
+// folderSettings merges the folder settings of dir and its ancestors up to
+// baseDir, the nearest folder winning for each setting
+func (r *BruRepository) folderSettings(dir, baseDir string, folders map[string]brunoformat.MockSettings) brunoformat.MockSettings {
+	var settings brunoformat.MockSettings
+	baseDir = filepath.Clean(baseDir)
+
+	for {
+		settings = settings.Inherit(folders[dir])
+		if dir == baseDir {
+			return settings
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return settings
+		}
+		dir = parent
+	}
+}
+
+// Snapshot fingerprints every .bru file and the environment files so callers can detect changes
+func (r *BruRepository) Snapshot(baseDir string, envNames []string) (map[string]FileStamp, error) {
+	stamps := make(map[string]FileStamp)
+
+	err := r.walkBruFiles(baseDir, func(path string, info os.FileInfo) {
+		stamps[path] = FileStamp{ModTime: info.ModTime(), Size: info.Size()}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, envName := range envNames {
+		envPath := r.environmentPath(envName, baseDir)
+		if info, err := os.Stat(envPath); err == nil {
+			stamps[envPath] = FileStamp{ModTime: info.ModTime(), Size: info.Size()}
+		}
+	}
+
+	return stamps, nil
+}
+
+// walkBruFiles calls fn for every .bru file under baseDir, skipping the
+// environments directory
+func (r *BruRepository) walkBruFiles(baseDir string, fn func(path string, info os.FileInfo)) error {
+	err := filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			log.Printf("Warning: failed to access path %s: %v", path, err)
+			return nil // Continue walking
+		}
+
+		// Skip directories
+		if info.IsDir() {
+			// Skip environments directory
+			if info.Name() == "environments" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Only process .bru files
+		if !strings.HasSuffix(info.Name(), ".bru") {
+			return nil
+		}
+
+		fn(path, info)
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to walk directory %s: %w", baseDir, err)
+	}
+	return nil
+}
+
 // LoadEnvironment loads environment variables from a .bru environment file
 func (r *BruRepository) LoadEnvironment(envName string, baseDir string) (map[string]string, error) {
-	envPath := filepath.Join(baseDir, "environments", envName+".bru")
+	envPath := r.environmentPath(envName, baseDir)
 
 	// If environment file doesn't exist, return empty map (not an error)
 	if _, err := os.Stat(envPath); os.IsNotExist(err) {
 		return make(map[string]string), nil
 	}
 
-	content, err := os.ReadFile(envPath)
+	file, err := brunoformat.ParseFile(envPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read environment file %s: %w", envPath, err)
+		return nil, fmt.Errorf("failed to load environment file %s: %w", envPath, err)
 	}
 
-	vars := r.parseVarsBlock(string(content))
-	return vars, nil
-}
-
-// parseVarsBlock parses the vars { ... } block from an environment file
-func (r *BruRepository) parseVarsBlock(content string) map[string]string {
 	vars := make(map[string]string)
-
-	// Extract vars { ... } block
-	varsRe := regexp.MustCompile(`vars\s*\{([^}]*)\}`)
-	match := varsRe.FindStringSubmatch(content)
-	if match == nil {
-		return vars
+	if block := file.Block("vars"); block != nil {
+		vars = block.Map()
 	}
 
-	// Parse key: value pairs
-	lines := strings.Split(match[1], "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			vars[key] = value
-		}
-	}
+	return vars, nil
+}
 
-	return vars
+// environmentPath returns the location of a named environment file
+func (r *BruRepository) environmentPath(envName, baseDir string) string {
+	return filepath.Join(baseDir, "environments", envName+".bru")
 }
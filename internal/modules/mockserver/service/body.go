@@ -0,0 +1,157 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/anu-mdl/linker-bruno/internal/shared/brunoformat"
+)
+
+// contentTypes maps example body types to the Content-Type served by default
+var contentTypes = map[string]string{
+	"":                     "application/json",
+	brunoformat.BodyJSON:   "application/json",
+	brunoformat.BodyText:   "text/plain; charset=utf-8",
+	brunoformat.BodyXML:    "application/xml",
+	brunoformat.BodyHTML:   "text/html; charset=utf-8",
+	brunoformat.BodyBase64: "application/octet-stream",
+	brunoformat.BodyBinary: "application/octet-stream",
+	brunoformat.BodySSE:    "text/event-stream",
+	brunoformat.BodyStream: "text/plain; charset=utf-8",
+}
+
+// prepareBody resolves the parts of an example body that do not depend on the
+// request: decoded binary content, stream chunks, the fixture path and the
+// default Content-Type. Fixtures must lie inside the collection directory.
+func prepareBody(baseDir string, req *brunoformat.BrunoRequest, example *brunoformat.ExampleBlock, re *routeExample) error {
+	body := example.Response.Body
+	re.contentType = contentTypes[body.Type]
+
+	if body.IsStream() {
+		if body.File != "" {
+			return fmt.Errorf("%s: example %q: %s bodies cannot be read from a file", req.FilePath, example.Name, body.Type)
+		}
+		chunks, err := parseStream(body.Content)
+		if err != nil {
+			return fmt.Errorf("%s: example %q body: %w", req.FilePath, example.Name, err)
+		}
+		re.stream = chunks
+		return nil
+	}
+
+	if body.File != "" {
+		path := body.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(req.FilePath), path)
+		}
+		if !insideDir(baseDir, path) {
+			return fmt.Errorf("%s: example %q body file %s is outside the collection directory", req.FilePath, example.Name, body.File)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("%s: example %q body file: %w", req.FilePath, example.Name, err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("%s: example %q body file %s is a directory", req.FilePath, example.Name, path)
+		}
+		re.file = path
+
+		// Without an explicit textual type the fixture's extension decides
+		if body.Type == "" || body.IsBinary() {
+			if byExt := mime.TypeByExtension(filepath.Ext(path)); byExt != "" {
+				re.contentType = byExt
+			} else {
+				re.contentType = "application/octet-stream"
+			}
+		}
+		return nil
+	}
+
+	if body.IsBinary() {
+		raw, err := base64.StdEncoding.DecodeString(stripWhitespace(body.Content))
+		if err != nil {
+			return fmt.Errorf("%s: example %q body is not valid base64: %w", req.FilePath, example.Name, err)
+		}
+		re.raw = raw
+	}
+	return nil
+}
+
+// stripWhitespace removes line breaks and indentation from wrapped base64 content
+func stripWhitespace(s string) string {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case ' ', '\t', '\r', '\n':
+		default:
+			out = append(out, s[i])
+		}
+	}
+	return string(out)
+}
+
+// writeFile streams a fixture file with the given status. Its size and
+// modification time serve as validators, and text files are compressed on
+// the fly when the client accepts it.
+func (s *MockService) writeFile(w http.ResponseWriter, r *http.Request, path string, status int) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("Error opening body file %s: %v", path, err)
+		http.Error(w, "failed to open body file", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		log.Printf("Error reading body file %s: %v", path, err)
+		http.Error(w, "failed to read body file", http.StatusInternalServerError)
+		return
+	}
+
+	encoding := responseEncoding(w, r, info.Size())
+	etag := fmt.Sprintf("W/\"%x-%x", info.Size(), info.ModTime().UnixNano())
+	if encoding != "" {
+		etag += "-" + encoding
+	}
+	setValidators(w.Header(), etag+`"`, info.ModTime())
+	if notModified(w, r, status) {
+		return
+	}
+
+	var out io.Writer = w
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+		enc := encoder(w, encoding)
+		defer enc.Close()
+		out = enc
+	} else {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	}
+	w.WriteHeader(status)
+	if _, err := io.Copy(out, f); err != nil {
+		log.Printf("Error streaming body file %s: %v", path, err)
+	}
+}
+
+// insideDir reports whether path lies inside dir, comparing absolute paths
+func insideDir(dir, path string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// minCompressSize is the smallest body worth compressing
+const minCompressSize = 256
+
+// responseEncoding picks the Content-Encoding for a response body of the
+// given size from the request's Accept-Encoding: gzip, deflate, or "" to
+// send it as is. Responses that could be compressed vary by Accept-Encoding.
+func responseEncoding(w http.ResponseWriter, r *http.Request, size int64) string {
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || size < minCompressSize || !compressible(h.Get("Content-Type")) {
+		return ""
+	}
+	h.Add("Vary", "Accept-Encoding")
+
+	accept := r.Header.Get("Accept-Encoding")
+	best, bestQ := "", 0.0
+	for _, coding := range []string{"gzip", "deflate"} {
+		if q := encodingQuality(accept, coding); q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// encodingQuality returns the q value Accept-Encoding gives a content coding:
+// that of the coding itself, else that of *, else 0
+func encodingQuality(header, coding string) float64 {
+	q, found := 0.0, false
+	for _, entry := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(entry, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != coding && (name != "*" || found) {
+			continue
+		}
+
+		value := 1.0
+		if key, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.EqualFold(strings.TrimSpace(key), "q") {
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				value = parsed
+			}
+		}
+		q = value
+		found = name == coding
+	}
+	return q
+}
+
+// compressible reports whether a Content-Type is text that compresses well
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.Contains(mediaType, "json") ||
+		strings.Contains(mediaType, "xml") ||
+		strings.Contains(mediaType, "javascript")
+}
+
+// encoder wraps w in a compressor for a content coding from responseEncoding
+func encoder(w io.Writer, encoding string) io.WriteCloser {
+	if encoding == "gzip" {
+		return gzip.NewWriter(w)
+	}
+	return zlib.NewWriter(w)
+}
+
+// compress returns body compressed with a content coding from responseEncoding
+func compress(body []byte, encoding string) []byte {
+	var buf bytes.Buffer
+	enc := encoder(&buf, encoding)
+	enc.Write(body)
+	enc.Close()
+	return buf.Bytes()
+}
+
+// bodyETag returns a strong ETag for a rendered body. Compressed
+// representations get their own tag, as they differ byte for byte.
+func bodyETag(body []byte, encoding string) string {
+	sum := sha256.Sum256(body)
+	tag := hex.EncodeToString(sum[:8])
+	if encoding != "" {
+		tag += "-" + encoding
+	}
+	return `"` + tag + `"`
+}
+
+// setValidators sets the ETag and, unless zero, the Last-Modified time of a
+// response, keeping values the example sets itself
+func setValidators(h http.Header, etag string, modified time.Time) {
+	if h.Get("ETag") == "" {
+		h.Set("ETag", etag)
+	}
+	if h.Get("Last-Modified") == "" && !modified.IsZero() {
+		h.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// notModified answers a conditional GET or HEAD with 304 Not Modified if the
+// client's copy is current, judged by If-None-Match or, without it,
+// If-Modified-Since against the validators already set on w
+func notModified(w http.ResponseWriter, r *http.Request, status int) bool {
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || status != http.StatusOK {
+		return false
+	}
+
+	h := w.Header()
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if !etagMatches(match, h.Get("ETag")) {
+			return false
+		}
+	} else if since := r.Header.Get("If-Modified-Since"); since != "" {
+		t, err := http.ParseTime(since)
+		if err != nil {
+			return false
+		}
+		modified, err := http.ParseTime(h.Get("Last-Modified"))
+		if err != nil || modified.After(t) {
+			return false
+		}
+	} else {
+		return false
+	}
+
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches compares an If-None-Match list with an ETag, ignoring weakness
+func etagMatches(list, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/anu-mdl/linker-bruno/internal/shared/brunoformat"
+	"github.com/go-chi/chi/v5"
+)
+
+// paramPattern matches a chi route parameter, capturing its regexp if any
+var paramPattern = regexp.MustCompile(`\{[^}:]+(:[^}]*)?\}`)
+
+// routeShape reduces a chi pattern to what the router matches on, so that
+// /users/{id} and /users/{userId} have the same shape
+func routeShape(path string) string {
+	return paramPattern.ReplaceAllString(path, "{$1}")
+}
+
+// resolveConflicts checks the requests of a collection for routes declared
+// more than once. Of the requests declaring the same method and path, the
+// one with the highest mock priority is kept. Without priorities, the file
+// path that sorts first wins with a warning; a tie between explicit
+// priorities is an error naming all files involved. It returns the indexes
+// of the requests to register, in order.
+func resolveConflicts(requests []*brunoformat.BrunoRequest, paths []string) ([]int, error) {
+	priority := func(i int) int {
+		return requests[i].Mock.Inherit(requests[i].FolderMock).Priority
+	}
+	describe := func(i int) string {
+		return fmt.Sprintf("%s %s (%s)", requests[i].Method, paths[i], requests[i].FilePath)
+	}
+
+	// Group requests by route, keeping the order of first appearance
+	groups := make(map[string][]int)
+	var keys []string
+	for i, req := range requests {
+		key := req.Method + " " + routeShape(paths[i])
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	var keep []int
+	var errs []error
+	for _, key := range keys {
+		group := groups[key]
+		best := priority(group[0])
+		for _, i := range group[1:] {
+			best = max(best, priority(i))
+		}
+		tied := slices.DeleteFunc(slices.Clone(group), func(i int) bool { return priority(i) != best })
+
+		if len(tied) > 1 && best != 0 {
+			described := make([]string, len(tied))
+			for n, i := range tied {
+				described[n] = describe(i)
+			}
+			errs = append(errs, fmt.Errorf("duplicate route: %s all have priority %d; give one a higher priority to choose it", strings.Join(described, " and "), best))
+			continue
+		}
+
+		winner := slices.MinFunc(tied, func(a, b int) int {
+			return strings.Compare(requests[a].FilePath, requests[b].FilePath)
+		})
+		for _, i := range group {
+			switch {
+			case i == winner:
+			case priority(i) == best:
+				log.Printf("Warning: duplicate route: %s and %s; serving %s, the first by file path. Set a priority in their mock blocks to choose one",
+					describe(winner), describe(i), requests[winner].FilePath)
+			default:
+				log.Printf("Overridden: %s by %s (priority %d > %d)", describe(i), requests[winner].FilePath, best, priority(i))
+			}
+		}
+		keep = append(keep, winner)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	sort.Ints(keep)
+	warnParamNames(requests, paths, keep)
+	return keep, nil
+}
+
+// warnParamNames warns about routes that give the same path segment
+// different parameter names, like /users/{id} and /users/{userId}/posts.
+// The router handles them, but templates and match rules then have to use
+// a different name per route, which is easy to get wrong.
+func warnParamNames(requests []*brunoformat.BrunoRequest, paths []string, keep []int) {
+	segments := make(map[int][]string, len(keep))
+	for _, i := range keep {
+		segments[i] = strings.Split(paths[i], "/")
+	}
+
+	for x, i := range keep {
+		for _, j := range keep[x+1:] {
+			a, b := segments[i], segments[j]
+			for k := 0; k < len(a) && k < len(b); k++ {
+				if routeShape(a[k]) != routeShape(b[k]) {
+					break
+				}
+				if a[k] != b[k] {
+					log.Printf("Warning: %s in %s and %s in %s name the same path parameter differently (%s vs %s)",
+						paths[i], requests[i].FilePath, paths[j], requests[j].FilePath, a[k], b[k])
+					break
+				}
+			}
+		}
+	}
+}
+
+// registerRoute adds a route to the router, returning chi's panic for an
+// invalid pattern as an error
+func registerRoute(router *chi.Mux, method, path string, handler http.HandlerFunc) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("invalid route %s %s: %v", method, path, p)
+		}
+	}()
+	router.Method(method, path, handler)
+	return nil
+}
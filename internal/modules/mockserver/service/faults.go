@@ -0,0 +1,318 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/anu-mdl/linker-bruno/internal/shared/brunoformat"
+	"github.com/anu-mdl/linker-bruno/internal/shared/response"
+)
+
+// Fault kinds
+const (
+	FaultError    = "error"    // respond with a configured error status
+	FaultDrop     = "drop"     // close the connection without a response
+	FaultStall    = "stall"    // send the headers, then never send the body
+	FaultTruncate = "truncate" // close the connection halfway through the body
+)
+
+// FaultConfig holds fault injection rates as percentages of requests
+type FaultConfig struct {
+	ErrorRate    float64 `json:"errorRate,omitempty"`
+	ErrorStatus  int     `json:"errorStatus,omitempty"`
+	DropRate     float64 `json:"dropRate,omitempty"`
+	StallRate    float64 `json:"stallRate,omitempty"`
+	TruncateRate float64 `json:"truncateRate,omitempty"`
+}
+
+// faultConfigFrom converts the fault block of an example
+func faultConfigFrom(fault brunoformat.FaultSettings) *FaultConfig {
+	if fault.IsZero() {
+		return nil
+	}
+	return &FaultConfig{
+		ErrorRate:    fault.ErrorRate,
+		ErrorStatus:  fault.ErrorStatus,
+		DropRate:     fault.DropRate,
+		StallRate:    fault.StallRate,
+		TruncateRate: fault.TruncateRate,
+	}
+}
+
+// Validate checks that rates are percentages that add up to at most 100
+func (c FaultConfig) Validate() error {
+	for _, rate := range []float64{c.ErrorRate, c.DropRate, c.StallRate, c.TruncateRate} {
+		if rate < 0 || rate > 100 {
+			return fmt.Errorf("fault rates must be between 0 and 100")
+		}
+	}
+	if c.ErrorRate+c.DropRate+c.StallRate+c.TruncateRate > 100 {
+		return fmt.Errorf("fault rates add up to more than 100")
+	}
+	if c.ErrorStatus != 0 && (c.ErrorStatus < 100 || c.ErrorStatus > 999) {
+		return fmt.Errorf("invalid errorStatus %d", c.ErrorStatus)
+	}
+	return nil
+}
+
+// roll picks the fault to inject for one request, or "" for none
+func (c FaultConfig) roll() string {
+	u := rand.Float64() * 100
+	for _, fault := range []struct {
+		kind string
+		rate float64
+	}{
+		{FaultError, c.ErrorRate},
+		{FaultDrop, c.DropRate},
+		{FaultStall, c.StallRate},
+		{FaultTruncate, c.TruncateRate},
+	} {
+		if u < fault.rate {
+			return fault.kind
+		}
+		u -= fault.rate
+	}
+	return ""
+}
+
+// FaultState holds fault rules set through the admin API and counts every
+// injected fault. Rules for a route take precedence over the global rule,
+// and both take precedence over fault blocks in examples.
+type FaultState struct {
+	mu       sync.Mutex
+	global   *FaultConfig
+	routes   map[string]FaultConfig
+	counters map[faultKey]int
+}
+
+// faultKey identifies a fault counter
+type faultKey struct {
+	route string
+	fault string
+}
+
+// FaultCounter reports how often a fault was injected on a route
+type FaultCounter struct {
+	Route string `json:"route"`
+	Fault string `json:"fault"`
+	Count int    `json:"count"`
+}
+
+// NewFaultState creates a FaultState without rules
+func NewFaultState() *FaultState {
+	return &FaultState{
+		routes:   make(map[string]FaultConfig),
+		counters: make(map[faultKey]int),
+	}
+}
+
+// Set installs a rule for a route ("GET /users/{id}"), or globally when route is empty
+func (fs *FaultState) Set(route string, config FaultConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if route == "" {
+		fs.global = &config
+	} else {
+		fs.routes[route] = config
+	}
+	return nil
+}
+
+// Clear removes the rule for a route, or every rule when route is empty
+func (fs *FaultState) Clear(route string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if route == "" {
+		fs.global = nil
+		clear(fs.routes)
+	} else {
+		delete(fs.routes, route)
+	}
+}
+
+// Global returns the global rule, or nil if none is set
+func (fs *FaultState) Global() *FaultConfig {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.global
+}
+
+// Routes returns the per-route rules keyed by route
+func (fs *FaultState) Routes() map[string]FaultConfig {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	routes := make(map[string]FaultConfig, len(fs.routes))
+	for route, config := range fs.routes {
+		routes[route] = config
+	}
+	return routes
+}
+
+// rule returns the admin rule that applies to a route, if any
+func (fs *FaultState) rule(route string) (FaultConfig, bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if config, ok := fs.routes[route]; ok {
+		return config, true
+	}
+	if fs.global != nil {
+		return *fs.global, true
+	}
+	return FaultConfig{}, false
+}
+
+// record counts an injected fault
+func (fs *FaultState) record(route, fault string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.counters[faultKey{route: route, fault: fault}]++
+}
+
+// Counters returns all fault counters sorted by route and fault
+func (fs *FaultState) Counters() []FaultCounter {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	counters := make([]FaultCounter, 0, len(fs.counters))
+	for key, count := range fs.counters {
+		counters = append(counters, FaultCounter{Route: key.route, Fault: key.fault, Count: count})
+	}
+	sort.Slice(counters, func(i, j int) bool {
+		if counters[i].Route != counters[j].Route {
+			return counters[i].Route < counters[j].Route
+		}
+		return counters[i].Fault < counters[j].Fault
+	})
+	return counters
+}
+
+// ResetCounters sets all fault counters back to zero
+func (fs *FaultState) ResetCounters() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	clear(fs.counters)
+}
+
+// faultsDecidedKey marks requests whose fault was already decided by an admin rule
+type faultsDecidedKey struct{}
+
+// createFaultHandler wraps a route handler with the fault rules from the
+// admin API. When a rule applies, fault blocks in examples are ignored.
+func (s *MockService) createFaultHandler(rt *route, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		config, ok := s.faults.rule(rt.key)
+		if !ok {
+			next(w, r)
+			return
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), faultsDecidedKey{}, true))
+		if fault := config.roll(); fault != "" {
+			s.injectFault(w, r, rt.key, fault, config, next)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// exampleFault returns the fault to inject from an example's fault block, or "" for none
+func (s *MockService) exampleFault(r *http.Request, selected *routeExample) string {
+	if selected.fault == nil || r.Context().Value(faultsDecidedKey{}) != nil {
+		return ""
+	}
+	return selected.fault.roll()
+}
+
+// injectFault replaces or sabotages the response produced by serve
+func (s *MockService) injectFault(w http.ResponseWriter, r *http.Request, route, fault string, config FaultConfig, serve http.HandlerFunc) {
+	s.faults.record(route, fault)
+	log.Printf("Injecting %s fault into %s %s", fault, r.Method, r.URL.Path)
+
+	switch fault {
+	case FaultError:
+		status := config.ErrorStatus
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		response.WriteError(w, status, "INJECTED_FAULT", "fault injected by mock server")
+	case FaultDrop:
+		dropConnection(w)
+	case FaultStall:
+		sw := &stallWriter{ResponseWriter: w, ctx: r.Context()}
+		serve(sw, r)
+		sw.WriteHeader(http.StatusOK) // stall even if nothing was written
+	case FaultTruncate:
+		tw := &truncateWriter{ResponseWriter: w, status: http.StatusOK}
+		serve(tw, r)
+		tw.cut()
+	}
+}
+
+// dropConnection closes the client connection without writing a response
+func dropConnection(w http.ResponseWriter) {
+	if conn, _, err := http.NewResponseController(w).Hijack(); err == nil {
+		conn.Close()
+		return
+	}
+	// HTTP/2 connections cannot be hijacked; aborting resets the stream
+	panic(http.ErrAbortHandler)
+}
+
+// stallWriter sends the response headers, then blocks until the client gives up
+type stallWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	stalled bool
+}
+
+func (sw *stallWriter) WriteHeader(code int) {
+	if sw.stalled {
+		return
+	}
+	sw.stalled = true
+	sw.ResponseWriter.WriteHeader(code)
+	http.NewResponseController(sw.ResponseWriter).Flush()
+	<-sw.ctx.Done()
+}
+
+func (sw *stallWriter) Write(b []byte) (int, error) {
+	sw.WriteHeader(http.StatusOK)
+	return 0, sw.ctx.Err()
+}
+
+// truncateWriter buffers the response so cut can announce the full length
+// but send only the first half
+type truncateWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (tw *truncateWriter) WriteHeader(code int) {
+	tw.status = code
+}
+
+func (tw *truncateWriter) Write(b []byte) (int, error) {
+	return tw.body.Write(b)
+}
+
+// cut writes half of the buffered body and aborts the connection
+func (tw *truncateWriter) cut() {
+	body := tw.body.Bytes()
+	tw.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	tw.ResponseWriter.WriteHeader(tw.status)
+	tw.ResponseWriter.Write(body[:len(body)/2])
+	http.NewResponseController(tw.ResponseWriter).Flush()
+	panic(http.ErrAbortHandler)
+}
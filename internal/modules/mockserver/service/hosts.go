@@ -0,0 +1,95 @@
+package service
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/anu-mdl/linker-bruno/internal/shared/urlutil"
+	"github.com/go-chi/chi/v5"
+)
+
+// hostRouter dispatches requests by their Host header to the routes of
+// that host, and requests for other hosts to the fallback routes
+type hostRouter struct {
+	hosts    map[string]*chi.Mux
+	fallback *chi.Mux
+}
+
+// router returns the router for the host a request is addressed to
+func (hr *hostRouter) router(r *http.Request) *chi.Mux {
+	if router, ok := hr.hosts[urlutil.NormalizeHost(r.Host)]; ok {
+		return router
+	}
+	return hr.fallback
+}
+
+func (hr *hostRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hr.router(r).ServeHTTP(w, r)
+}
+
+// buildHostRouter groups routes by the host of their URL and builds a
+// router per host with build, which registers the routes with the given
+// indexes for the host, empty for the routes without a host. Routes
+// without a host are served for every host. Requests for
+// a host without routes of its own go to the fallback host's routes, or
+// to the routes without a host if there is no fallback host.
+func buildHostRouter(hosts []string, fallback string, build func(host string, indexes []int) (*chi.Mux, error)) (*hostRouter, error) {
+	names := hostNames(hosts)
+
+	hr := &hostRouter{hosts: make(map[string]*chi.Mux, len(names))}
+	for _, name := range names {
+		var indexes []int
+		for i, host := range hosts {
+			if host == name || host == "" {
+				indexes = append(indexes, i)
+			}
+		}
+		router, err := build(name, indexes)
+		if err != nil {
+			return nil, err
+		}
+		hr.hosts[name] = router
+	}
+
+	if router, ok := hr.hosts[fallback]; ok {
+		hr.fallback = router
+		return hr, nil
+	}
+	var indexes []int
+	for i, host := range hosts {
+		if host == "" {
+			indexes = append(indexes, i)
+		}
+	}
+	router, err := build("", indexes)
+	if err != nil {
+		return nil, err
+	}
+	hr.fallback = router
+	return hr, nil
+}
+
+// fallbackHost returns the host whose routes answer requests for hosts
+// without routes of their own: the configured default host, or the only
+// host if the routes name just one, so a single base URL like
+// http://localhost:3000 keeps matching whatever address the server is reached at
+func (s *MockService) fallbackHost(hosts []string) string {
+	if s.defaultHost != "" {
+		return s.defaultHost
+	}
+	if names := hostNames(hosts); len(names) == 1 {
+		return names[0]
+	}
+	return ""
+}
+
+// hostNames returns the distinct non-empty hosts, in order of appearance
+func hostNames(hosts []string) []string {
+	var names []string
+	for _, host := range hosts {
+		if host != "" && !slices.Contains(names, host) {
+			names = append(names, host)
+		}
+	}
+	return names
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anu-mdl/linker-bruno/internal/shared/jsonpath"
+)
+
+const (
+	// DefaultJournalSize is the number of requests kept in the journal
+	DefaultJournalSize = 1000
+	// maxJournalBody caps how much of a request body is kept per entry
+	maxJournalBody = 64 << 10
+)
+
+// JournalEntry is a request received by the mock server and how it was answered
+type JournalEntry struct {
+	ID         int64             `json:"id"`
+	Time       time.Time         `json:"time"`
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Query      string            `json:"query,omitempty"`
+	Route      string            `json:"route,omitempty"`    // matched route, e.g. "GET /users/{id}"
+	FilePath   string            `json:"filePath,omitempty"` // .bru file of the matched route
+	Example    string            `json:"example,omitempty"`  // name of the served example
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body,omitempty"`
+	Status     int               `json:"status"` // 0 if no response was written
+	DurationMs float64           `json:"durationMs"`
+
+	body       interface{} // decoded JSON body, for filters
+	bodyIsJSON bool
+}
+
+// JournalFilter selects journal entries. Empty fields match everything.
+type JournalFilter struct {
+	Method string `json:"method,omitempty"`
+	// Path is the exact request path or a glob like /orders/*
+	Path string `json:"path,omitempty"`
+	// Route is the matched route, e.g. "GET /users/{id}"
+	Route string `json:"route,omitempty"`
+	// File matches the end of the matched .bru file path
+	File   string `json:"file,omitempty"`
+	Status int    `json:"status,omitempty"`
+	// Headers, Query and Body map names (JSON paths for Body) to expected values
+	Headers map[string]string      `json:"headers,omitempty"`
+	Query   map[string]string      `json:"query,omitempty"`
+	Body    map[string]interface{} `json:"body,omitempty"`
+}
+
+// Matches reports whether an entry satisfies the filter
+func (f JournalFilter) Matches(e JournalEntry) bool {
+	if f.Method != "" && !strings.EqualFold(f.Method, e.Method) {
+		return false
+	}
+	if f.Path != "" && f.Path != e.Path {
+		if ok, _ := path.Match(f.Path, e.Path); !ok {
+			return false
+		}
+	}
+	if f.Route != "" && f.Route != e.Route {
+		return false
+	}
+	if f.File != "" && !strings.HasSuffix(filepath.ToSlash(e.FilePath), f.File) {
+		return false
+	}
+	if f.Status != 0 && f.Status != e.Status {
+		return false
+	}
+
+	for name, want := range f.Headers {
+		if e.Headers[http.CanonicalHeaderKey(name)] != want {
+			return false
+		}
+	}
+	if len(f.Query) > 0 {
+		query, _ := url.ParseQuery(e.Query)
+		for name, want := range f.Query {
+			if values, ok := query[name]; !ok || values[0] != want {
+				return false
+			}
+		}
+	}
+	for field, want := range f.Body {
+		if !e.bodyIsJSON {
+			return false
+		}
+		actual, ok := jsonpath.Lookup(e.body, field)
+		if !ok || jsonpath.Stringify(actual) != jsonpath.Stringify(want) {
+			return false
+		}
+	}
+	return true
+}
+
+// Journal keeps the most recent requests in a fixed-size ring buffer
+type Journal struct {
+	mu      sync.Mutex
+	entries []JournalEntry
+	start   int // index of the oldest entry once the buffer is full
+	nextID  int64
+}
+
+// NewJournal creates a Journal that keeps up to size requests
+func NewJournal(size int) *Journal {
+	return &Journal{entries: make([]JournalEntry, 0, size)}
+}
+
+// add appends an entry, evicting the oldest when the journal is full
+func (j *Journal) add(e JournalEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.nextID++
+	e.ID = j.nextID
+	if len(j.entries) < cap(j.entries) {
+		j.entries = append(j.entries, e)
+		return
+	}
+	j.entries[j.start] = e
+	j.start = (j.start + 1) % len(j.entries)
+}
+
+// Entries returns the entries matching the filter, oldest first
+func (j *Journal) Entries(filter JournalFilter) []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries := make([]JournalEntry, 0)
+	for i := range j.entries {
+		e := j.entries[(j.start+i)%len(j.entries)]
+		if filter.Matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Count returns the number of entries matching the filter
+func (j *Journal) Count(filter JournalFilter) int {
+	return len(j.Entries(filter))
+}
+
+// Clear removes all entries
+func (j *Journal) Clear() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = j.entries[:0]
+	j.start = 0
+}
+
+// journalEntryKey carries the entry of the current request to the route handlers
+type journalEntryKey struct{}
+
+// journalRequests records every request that reaches the route table
+func (s *MockService) journalRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		entry := &JournalEntry{
+			Time:    started,
+			Method:  r.Method,
+			Path:    r.URL.Path,
+			Query:   r.URL.RawQuery,
+			Headers: make(map[string]string, len(r.Header)),
+		}
+		for key, values := range r.Header {
+			entry.Headers[key] = strings.Join(values, ", ")
+		}
+		s.journalBody(r, entry)
+
+		sw := &statusWriter{ResponseWriter: w}
+		// Record even if the handler aborts, e.g. for injected faults
+		defer func() {
+			entry.Status = sw.status
+			entry.DurationMs = float64(time.Since(started).Microseconds()) / 1000
+			s.journal.add(*entry)
+		}()
+
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), journalEntryKey{}, entry)))
+	})
+}
+
+// journalBody keeps the start of the request body and puts the whole body
+// back on the request
+func (s *MockService) journalBody(r *http.Request, entry *JournalEntry) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(r.Body, maxJournalBody))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), r.Body), r.Body}
+
+	entry.Body = string(data)
+	if len(bytes.TrimSpace(data)) > 0 && json.Unmarshal(data, &entry.body) == nil {
+		entry.bodyIsJSON = true
+	}
+}
+
+// journalRoute notes the matched route and .bru file on the request's journal entry
+func (s *MockService) journalRoute(rt *route, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if entry, ok := r.Context().Value(journalEntryKey{}).(*JournalEntry); ok {
+			entry.Route = rt.key
+			entry.FilePath = rt.request.FilePath
+		}
+		next(w, r)
+	}
+}
+
+// journalExample notes the served example on the request's journal entry
+func journalExample(r *http.Request, selected *routeExample) {
+	if entry, ok := r.Context().Value(journalEntryKey{}).(*JournalEntry); ok {
+		entry.Example = selected.example.Name
+	}
+}
+
+// statusWriter remembers the status code written by a handler
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if sw.status == 0 {
+		sw.status = code
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the connection for flushing and hijacking
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/anu-mdl/linker-bruno/internal/shared/brunoformat"
+	"github.com/anu-mdl/linker-bruno/internal/shared/jsonpath"
+)
+
+// listParams are query parameters with a meaning of their own, which are
+// never taken as filters
+var listParams = []string{"sort", "order", "page", "limit", "offset", "cursor", ExampleQueryParam}
+
+// filterOperators are the suffixes of filter parameters, e.g. ?name_like=jo
+var filterOperators = []string{"_like", "_ne", "_gte", "_lte"}
+
+// listing filters, sorts and pages the JSON array bodies of a route
+type listing struct {
+	filter     bool
+	sort       bool
+	pagination *pagination // nil unless paginated
+}
+
+// newListing returns the listing configured by mock settings, or nil if
+// array bodies are served as they are
+func newListing(settings brunoformat.MockSettings) *listing {
+	l := &listing{
+		filter:     settings.Filtered(),
+		sort:       settings.Sorted(),
+		pagination: newPagination(settings),
+	}
+	if !l.filter && !l.sort && l.pagination == nil {
+		return nil
+	}
+	return l
+}
+
+// listItem is an array element with its decoded value for filters and sorting
+type listItem struct {
+	raw   json.RawMessage
+	value interface{}
+}
+
+// apply filters, sorts and pages a JSON array body according to the
+// request's query parameters. Bodies that are not JSON arrays are returned
+// unchanged. Invalid parameters are returned as an error for a 400 response.
+func (l *listing) apply(r *http.Request, header http.Header, body string) (string, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal([]byte(body), &raw); err != nil {
+		return body, nil
+	}
+
+	items := make([]listItem, len(raw))
+	for i, data := range raw {
+		items[i].raw = data
+		if l.filter || l.sort {
+			json.Unmarshal(data, &items[i].value)
+		}
+	}
+
+	query := r.URL.Query()
+	if l.filter {
+		items = filterItems(items, query)
+	}
+	if l.sort {
+		if err := sortItems(items, query); err != nil {
+			return "", err
+		}
+	}
+
+	raw = raw[:0]
+	for _, item := range items {
+		raw = append(raw, item.raw)
+	}
+	if l.pagination != nil {
+		return l.pagination.apply(r, header, raw)
+	}
+	data, err := json.Marshal(raw)
+	return string(data), err
+}
+
+// filterItems keeps the items matching every filter parameter:
+//
+//	?status=active          equal (repeat the parameter to allow several values)
+//	?status_ne=archived     not equal
+//	?name_like=jo           contains, ignoring case
+//	?age_gte=18&age_lte=65  at least / at most
+//
+// Field names may be dotted paths like author.name. Parameters naming a
+// field that no item has are ignored, so unrelated query parameters do not
+// empty the list.
+func filterItems(items []listItem, query url.Values) []listItem {
+	// Fields are looked up in all items, since the order filters run in is random
+	all := slices.Clone(items)
+	for key, values := range query {
+		if slices.Contains(listParams, key) {
+			continue
+		}
+
+		field, operator := key, ""
+		if !hasField(all, key) {
+			for _, suffix := range filterOperators {
+				if name, ok := strings.CutSuffix(key, suffix); ok {
+					field, operator = name, suffix
+					break
+				}
+			}
+		}
+		if !hasField(all, field) {
+			continue
+		}
+
+		items = slices.DeleteFunc(items, func(item listItem) bool {
+			actual, ok := jsonpath.Lookup(item.value, field)
+			return !ok || !matchFilter(actual, operator, values)
+		})
+	}
+	return items
+}
+
+// hasField reports whether any item has a value at the given path
+func hasField(items []listItem, field string) bool {
+	for _, item := range items {
+		if _, ok := jsonpath.Lookup(item.value, field); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchFilter reports whether a value satisfies a filter operator for all
+// of the given values, or any of them for equality
+func matchFilter(actual interface{}, operator string, values []string) bool {
+	text := jsonpath.Stringify(actual)
+	switch operator {
+	case "":
+		return slices.Contains(values, text)
+	case "_ne":
+		return !slices.Contains(values, text)
+	}
+
+	for _, value := range values {
+		var ok bool
+		switch operator {
+		case "_like":
+			ok = strings.Contains(strings.ToLower(text), strings.ToLower(value))
+		case "_gte":
+			ok = compareValues(actual, parseFilterValue(value)) >= 0
+		case "_lte":
+			ok = compareValues(actual, parseFilterValue(value)) <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// parseFilterValue interprets a query value as a number if it is one, so
+// that ?price_gte=10 compares numerically
+func parseFilterValue(value string) interface{} {
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		return n
+	}
+	return value
+}
+
+// sortItems sorts items in place by ?sort=field[,field...] and
+// ?order=asc|desc[,...]. A field prefixed with - sorts descending. Items
+// without the field come last in either order.
+func sortItems(items []listItem, query url.Values) error {
+	fields := splitParam(query.Get("sort"))
+	if len(fields) == 0 {
+		return nil
+	}
+	orders := splitParam(query.Get("order"))
+
+	descending := make([]bool, len(fields))
+	for i, field := range fields {
+		if name, ok := strings.CutPrefix(field, "-"); ok {
+			fields[i], descending[i] = name, true
+		}
+		if i < len(orders) {
+			switch strings.ToLower(orders[i]) {
+			case "asc":
+			case "desc":
+				descending[i] = !descending[i]
+			default:
+				return fmt.Errorf("invalid order %q: expected asc or desc", orders[i])
+			}
+		}
+	}
+
+	slices.SortStableFunc(items, func(a, b listItem) int {
+		for i, field := range fields {
+			x, xok := jsonpath.Lookup(a.value, field)
+			y, yok := jsonpath.Lookup(b.value, field)
+			switch {
+			case !xok && !yok:
+				continue
+			case !xok:
+				return 1
+			case !yok:
+				return -1
+			}
+			if c := compareValues(x, y); c != 0 {
+				if descending[i] {
+					return -c
+				}
+				return c
+			}
+		}
+		return 0
+	})
+	return nil
+}
+
+// compareValues orders JSON values: numbers numerically, booleans false
+// first, everything else by its text, which also orders ISO 8601 dates
+func compareValues(a, b interface{}) int {
+	switch x := a.(type) {
+	case float64:
+		if y, ok := b.(float64); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0
+			case !x:
+				return -1
+			}
+			return 1
+		}
+	}
+	return strings.Compare(jsonpath.Stringify(a), jsonpath.Stringify(b))
+}
+
+// splitParam splits a comma-separated query value, dropping empty entries
+func splitParam(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
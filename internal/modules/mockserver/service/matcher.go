@@ -0,0 +1,115 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/anu-mdl/linker-bruno/internal/shared/brunoformat"
+)
+
+// condition is a match rule compiled for evaluation
+type condition struct {
+	rule   brunoformat.MatchRule
+	re     *regexp.Regexp
+	list   []string
+	number float64
+}
+
+// compileConditions validates match rules and precompiles regexes, lists and numbers
+func compileConditions(rules []brunoformat.MatchRule) ([]condition, error) {
+	conditions := make([]condition, 0, len(rules))
+
+	for _, rule := range rules {
+		c := condition{rule: rule}
+
+		switch rule.Operator {
+		case "matches", "notMatches":
+			re, err := regexp.Compile(rule.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid regex in %q: %w", rule.Pos, rule.String(), err)
+			}
+			c.re = re
+		case "in", "notIn":
+			for _, item := range strings.Split(rule.Value, ",") {
+				c.list = append(c.list, strings.TrimSpace(item))
+			}
+		case "gt", "gte", "lt", "lte":
+			number, err := strconv.ParseFloat(rule.Value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %q needs a numeric operand", rule.Pos, rule.String())
+			}
+			c.number = number
+		}
+
+		conditions = append(conditions, c)
+	}
+
+	return conditions, nil
+}
+
+// matches evaluates the condition against a request
+func (c condition) matches(info *requestInfo) bool {
+	actual, present := info.lookup(c.rule.Source, c.rule.Key)
+
+	switch c.rule.Operator {
+	case "isDefined":
+		return present
+	case "isUndefined":
+		return !present
+	case "neq":
+		return !present || actual != c.rule.Value
+	case "notIn":
+		return !present || !slices.Contains(c.list, actual)
+	case "notMatches":
+		return !present || !c.re.MatchString(actual)
+	}
+
+	if !present {
+		return false
+	}
+
+	switch c.rule.Operator {
+	case "eq":
+		return actual == c.rule.Value
+	case "in":
+		return slices.Contains(c.list, actual)
+	case "contains":
+		return strings.Contains(actual, c.rule.Value)
+	case "startsWith":
+		return strings.HasPrefix(actual, c.rule.Value)
+	case "endsWith":
+		return strings.HasSuffix(actual, c.rule.Value)
+	case "matches":
+		return c.re.MatchString(actual)
+	case "gt", "gte", "lt", "lte":
+		number, err := strconv.ParseFloat(actual, 64)
+		if err != nil {
+			return false
+		}
+		switch c.rule.Operator {
+		case "gt":
+			return number > c.number
+		case "gte":
+			return number >= c.number
+		case "lt":
+			return number < c.number
+		default:
+			return number <= c.number
+		}
+	}
+
+	return false
+}
+
+// matchAll reports whether every condition holds for the request
+func matchAll(conditions []condition, info *requestInfo) bool {
+	for _, c := range conditions {
+		if !c.matches(info) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anu-mdl/linker-bruno/internal/shared/brunoformat"
+)
+
+func TestConditionMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []brunoformat.MatchRule
+		want  bool
+	}{
+		{name: "query eq", rules: rules("query.role: eq admin"), want: true},
+		{name: "query eq other value", rules: rules("query.role: eq user"), want: false},
+		{name: "query eq missing", rules: rules("query.missing: eq admin"), want: false},
+		{name: "neq missing", rules: rules("query.missing: neq admin"), want: true},
+		{name: "header case-insensitive name", rules: rules("headers.x-tenant: eq acme"), want: true},
+		{name: "in", rules: rules("headers.X-Tenant: in other, acme"), want: true},
+		{name: "notIn", rules: rules("headers.X-Tenant: notIn other, acme"), want: false},
+		{name: "contains", rules: rules("body.user.email: contains @example"), want: true},
+		{name: "startsWith", rules: rules("body.user.email: startsWith ada"), want: true},
+		{name: "endsWith", rules: rules("body.user.email: endsWith .com"), want: false},
+		{name: "matches", rules: rules(`body.user.email: matches ^[a-z]+@`), want: true},
+		{name: "notMatches", rules: rules(`query.role: notMatches ^adm`), want: false},
+		{name: "gt", rules: rules("body.user.age: gt 18"), want: true},
+		{name: "gte equal", rules: rules("body.user.age: gte 36"), want: true},
+		{name: "lt", rules: rules("body.user.age: lt 36"), want: false},
+		{name: "lte query number", rules: rules("query.page: lte 2"), want: true},
+		{name: "number operator on text", rules: rules("query.role: gt 1"), want: false},
+		{name: "nested array path", rules: rules("body.items[1].sku: eq B-2"), want: true},
+		{name: "boolean body field", rules: rules("body.user.active: eq true"), want: true},
+		{name: "isDefined", rules: rules("body.user.age: isDefined"), want: true},
+		{name: "isUndefined", rules: rules("body.user.phone: isUndefined"), want: true},
+		{name: "all hold", rules: rules("query.role: eq admin", "body.user.age: gte 18"), want: true},
+		{name: "one fails", rules: rules("query.role: eq admin", "body.user.age: lt 18"), want: false},
+		{name: "no rules", rules: nil, want: true},
+	}
+
+	body := `{"user": {"email": "ada@example.org", "age": 36, "active": true}, "items": [{"sku": "A-1"}, {"sku": "B-2"}]}`
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conditions, err := compileConditions(tt.rules)
+			if err != nil {
+				t.Fatalf("compileConditions: %v", err)
+			}
+
+			r := httptest.NewRequest("POST", "/users?role=admin&page=2", strings.NewReader(body))
+			r.Header.Set("X-Tenant", "acme")
+			if got := matchAll(conditions, newRequestInfo(r)); got != tt.want {
+				t.Errorf("matchAll() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileConditionsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		want string
+	}{
+		{name: "invalid regex", rule: "query.q: matches (", want: "invalid regex"},
+		{name: "numeric operand", rule: "query.page: gt two", want: "needs a numeric operand"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compileConditions(rules(tt.rule))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("compileConditions() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// rules parses match rules written as in a .bru match block
+func rules(lines ...string) []brunoformat.MatchRule {
+	src := "get {\n  url: /users\n}\n\nexample {\n  name: test\n\n  match: {\n    " +
+		strings.Join(lines, "\n    ") + "\n  }\n}\n"
+	file, err := brunoformat.Parse("test.bru", src)
+	if err != nil {
+		panic(err)
+	}
+	req, err := brunoformat.DecodeRequest(file)
+	if err != nil {
+		panic(err)
+	}
+	return req.Examples[0].Match
+}
@@ -1,82 +1,676 @@
 package service
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/anu-mdl/linker-bruno/internal/modules/mockserver/latency"
+	"github.com/anu-mdl/linker-bruno/internal/modules/mockserver/templating"
+	"github.com/anu-mdl/linker-bruno/internal/modules/webui/repository"
 	"github.com/anu-mdl/linker-bruno/internal/shared/brunoformat"
+	"github.com/anu-mdl/linker-bruno/internal/shared/middleware"
+	"github.com/anu-mdl/linker-bruno/internal/shared/response"
 	"github.com/anu-mdl/linker-bruno/internal/shared/urlutil"
 	"github.com/go-chi/chi/v5"
 )
 
-// MockService handles business logic for mock endpoint registration and response generation
+const (
+	// ExampleHeader names the example to serve for a single request
+	ExampleHeader = "X-Mock-Example"
+	// ExampleQueryParam is the query parameter alternative to ExampleHeader
+	ExampleQueryParam = "__example"
+)
+
+// Options configures optional mock server behaviour
+type Options struct {
+	// Stateful emulates CRUD resources in memory instead of serving static examples
+	Stateful bool
+	// Delay is applied to routes without a delay of their own; nil means none
+	Delay latency.Distribution
+	// ProxyTo receives requests that match no route and routes marked with
+	// proxy: true; nil disables proxying
+	ProxyTo *url.URL
+	// BaseDir is the collection directory. Fixture files must lie inside it,
+	// and runtime stubs are persisted to it using Files; persisting is
+	// unavailable when Files is nil
+	BaseDir string
+	Files   *repository.FileRepository
+	// JournalSize is the number of requests kept in the request journal; 0 disables it
+	JournalSize int
+	// DefaultHost serves requests for hosts that no route's URL names; empty
+	// means the only host if there is just one, otherwise routes without a host
+	DefaultHost string
+	// RateLimiter counts requests against rate limits; nil creates one
+	RateLimiter *middleware.RateLimiter
+}
+
+// MockService handles business logic for mock endpoint registration and response generation.
+// It outlives individual route tables, so state kept here survives reloads.
 type MockService struct {
 	converter *urlutil.Converter
+	store     *ResourceStore // nil unless stateful mode is enabled
+	scenarios *ScenarioState
+	faults    *FaultState
+	delay     latency.Distribution
+	proxy     http.Handler // nil unless --proxy-to is set
+	journal   *Journal     // nil when the request journal is disabled
+	stubs     *StubState
+	baseDir   string                     // collection directory
+	files     *repository.FileRepository // nil when stubs cannot be persisted
+	limiter   *middleware.RateLimiter
+	// defaultHost answers requests for unknown hosts; see Options.DefaultHost
+	defaultHost string
+}
+
+// route is a Bruno request prepared for serving
+type route struct {
+	key          string // method and chi pattern, identifies sequence counters
+	request      *brunoformat.BrunoRequest
+	examples     []*routeExample
+	sequence     []*routeExample
+	sequenceMode string
+	delay        latency.Distribution // from the mock block, folder.bru or --delay
+	env          map[string]string
+	listing      *listing              // nil unless the route filters, sorts or pages array bodies
+	mediaTypes   []string              // distinct media types of the examples, listed in 406 responses
+	rateLimit    *middleware.RateLimit // nil unless the route or its folder sets a rate limit
+	rateScope    string                // the file or folder whose rate limit applies
+	modified     time.Time             // modification time of the .bru file, zero if unknown
+}
+
+// routeExample is an example with its match conditions and templates compiled
+type routeExample struct {
+	example    *brunoformat.ExampleBlock
+	conditions []condition
+	body       *templating.Template
+	headers    map[string]*templating.Template
+	delay      latency.Distribution
+
+	// Body alternatives to the template; see prepareBody
+	raw         []byte        // decoded base64/binary content
+	file        string        // fixture path, streamed from disk
+	stream      []streamChunk // events or chunks of an sse or stream body
+	contentType string        // default Content-Type for the body type
+	mediaType   string        // media type served, for content negotiation
+	fault       *FaultConfig  // nil when the example injects no faults
 }
 
 // NewMockService creates a new MockService
-func NewMockService(converter *urlutil.Converter) *MockService {
-	return &MockService{
+func NewMockService(converter *urlutil.Converter, opts Options) *MockService {
+	s := &MockService{
 		converter: converter,
+		scenarios: NewScenarioState(),
+		faults:    NewFaultState(),
+		delay:     opts.Delay,
+		stubs:     NewStubState(),
+		baseDir:   opts.BaseDir,
+		files:     opts.Files,
+		limiter:   opts.RateLimiter,
+
+		defaultHost: urlutil.NormalizeHost(opts.DefaultHost),
+	}
+	if s.limiter == nil {
+		s.limiter = middleware.NewRateLimiter()
+	}
+	if opts.Stateful {
+		s.store = NewResourceStore()
 	}
+	if opts.ProxyTo != nil {
+		s.proxy = newProxy(opts.ProxyTo)
+	}
+	if opts.JournalSize > 0 {
+		s.journal = NewJournal(opts.JournalSize)
+	}
+	return s
+}
+
+// Scenarios returns the scenario and sequence state
+func (s *MockService) Scenarios() *ScenarioState {
+	return s.scenarios
+}
+
+// RateLimiter returns the rate limit counters
+func (s *MockService) RateLimiter() *middleware.RateLimiter {
+	return s.limiter
+}
+
+// Faults returns the fault injection rules and counters
+func (s *MockService) Faults() *FaultState {
+	return s.faults
+}
+
+// Journal returns the request journal, or nil when it is disabled
+func (s *MockService) Journal() *Journal {
+	return s.journal
+}
+
+// Store returns the resource store, or nil when stateful mode is disabled
+func (s *MockService) Store() *ResourceStore {
+	return s.store
+}
+
+// RouteInfo describes a registered mock route
+type RouteInfo struct {
+	Method   string
+	Host     string // empty unless the URL names a host; see buildHostRouter
+	Path     string
+	FilePath string
+	Proxied  bool // forwarded to the upstream instead of mocked
+	// Signature changes whenever the route's request definition changes
+	Signature string
 }
 
-// RegisterRoutes registers all Bruno requests as routes on the given router
-func (s *MockService) RegisterRoutes(router *chi.Mux, requests []*brunoformat.BrunoRequest, envVars map[string]string) error {
+// Key identifies the route by method, host and path
+func (ri RouteInfo) Key() string {
+	return ri.Method + " " + ri.Host + ri.Path
+}
+
+// BuildRouter builds a self-contained route table for the requests of the
+// named environment, dispatching by Host header; see buildHostRouter.
+// Unmatched requests are proxied if an upstream is set, else get a JSON 404.
+// Router panics are returned as errors so a bad reload cannot crash the server.
+func (s *MockService) BuildRouter(requests []*brunoformat.BrunoRequest, envName string, envVars map[string]string) (handler http.Handler, routes []RouteInfo, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			handler, routes, err = nil, nil, fmt.Errorf("failed to build routes: %v", p)
+		}
+	}()
+
+	hosts := make([]string, len(requests))
+	for i, req := range requests {
+		hosts[i] = s.converter.Host(req.URL, envVars)
+	}
+
+	if names := hostNames(hosts); s.defaultHost != "" && len(names) > 0 && !slices.Contains(names, s.defaultHost) {
+		log.Printf("Warning: no routes for default host %q (hosts: %s)", s.defaultHost, strings.Join(names, ", "))
+	}
+
+	registered := make(map[string]bool)
+	hr, err := buildHostRouter(hosts, s.fallbackHost(hosts), func(host string, indexes []int) (*chi.Mux, error) {
+		router := chi.NewRouter()
+		router.NotFound(s.notFound)
+		router.MethodNotAllowed(s.notFound)
+
+		group := make([]*brunoformat.BrunoRequest, len(indexes))
+		for k, i := range indexes {
+			group[k] = requests[i]
+		}
+		groupRoutes, err := s.RegisterRoutes(router, group, envName, host, envVars)
+		if err != nil {
+			return nil, err
+		}
+
+		// Routes without a host are registered for every host but listed once
+		for _, route := range groupRoutes {
+			if !registered[route.Key()] {
+				registered[route.Key()] = true
+				routes = append(routes, route)
+			}
+		}
+		return router, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scenarios := make(map[string]bool)
 	for _, req := range requests {
-		// Convert Bruno URL pattern to chi route pattern
-		path := s.converter.ConvertPattern(req.URL, envVars)
+		for _, example := range req.Examples {
+			if example.Scenario != "" {
+				scenarios[example.Scenario] = true
+			}
+		}
+	}
+	s.scenarios.setKnown(scenarios)
+
+	handler = s.serveStubs(envName)(hr)
+	if s.journal != nil {
+		handler = s.journalRequests(handler)
+	}
+	return handler, routes, nil
+}
+
+// RegisterRoutes registers all Bruno requests as routes on the given router,
+// which serves the named environment for host; stateful resources are kept
+// apart per environment and host
+func (s *MockService) RegisterRoutes(router *chi.Mux, requests []*brunoformat.BrunoRequest, envName, host string, envVars map[string]string) ([]RouteInfo, error) {
+	routes := make([]RouteInfo, 0, len(requests))
+
+	// Convert Bruno URL patterns to chi route patterns
+	paths := make([]string, len(requests))
+	for i, req := range requests {
+		paths[i] = s.converter.ConvertPattern(req.URL, envVars)
+	}
+
+	// Drop routes overridden by a higher priority file; fail on ties
+	keep, err := resolveConflicts(requests, paths)
+	if err != nil {
+		return nil, err
+	}
+	kept, keptPaths := make([]*brunoformat.BrunoRequest, len(keep)), make([]string, len(keep))
+	for k, i := range keep {
+		kept[k], keptPaths[k] = requests[i], paths[i]
+	}
+	requests, paths = kept, keptPaths
+
+	// Find collections to emulate as stateful resources
+	var resources map[string]string
+	if s.store != nil {
+		resources = s.detectResources(requests, paths, envName, host)
+	}
+
+	for i, req := range requests {
+		path := paths[i]
+
+		// Compile match conditions and templates once at registration
+		rt, err := s.newRoute(req, envVars)
+		if err != nil {
+			return nil, err
+		}
+		rt.key = req.Method + " " + path
 
 		// Create handler for this request
-		handler := s.createHandler(req)
+		handler, proxied := s.routeHandler(rt, path, resources)
 
 		// Register the route with the appropriate method
-		router.Method(req.Method, path, handler)
+		if err := registerRoute(router, req.Method, path, handler); err != nil {
+			return nil, fmt.Errorf("%s: %w", req.FilePath, err)
+		}
+
+		routes = append(routes, RouteInfo{
+			Method:    req.Method,
+			Host:      s.converter.Host(req.URL, envVars),
+			Path:      path,
+			FilePath:  req.FilePath,
+			Proxied:   proxied,
+			Signature: signature(req),
+		})
+	}
+
+	return routes, nil
+}
+
+// routeHandler creates the handler chain for a route. It reports whether
+// the route is forwarded to the upstream instead of mocked.
+func (s *MockService) routeHandler(rt *route, path string, resources map[string]string) (http.HandlerFunc, bool) {
+	req := rt.request
+	handler := s.createHandler(rt)
+	proxied := false
+	if req.Mock.Inherit(req.FolderMock).Proxied() {
+		if s.proxy != nil {
+			handler, proxied = s.proxy.ServeHTTP, true
+		} else {
+			log.Printf("Warning: %s is marked proxy: true but no upstream is set (--proxy-to); serving mock", req.FilePath)
+		}
+	} else if binding, ok := s.bindResource(req.Method, path, resources); ok {
+		handler = s.createStatefulHandler(binding, rt, handler)
+	}
+	handler = s.createFaultHandler(rt, handler)
+	handler = s.createRateLimitHandler(rt, handler)
+	if s.journal != nil {
+		handler = s.journalRoute(rt, handler)
+	}
+	return handler, proxied
+}
+
+// signature serializes a request definition so that any change to it shows up
+func signature(req *brunoformat.BrunoRequest) string {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Sprintf("%+v", *req)
+	}
+	return string(data)
+}
+
+// notFound answers requests that match no mock route, forwarding them to
+// the upstream if one is configured
+func (s *MockService) notFound(w http.ResponseWriter, r *http.Request) {
+	if s.proxy != nil {
+		s.proxy.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":  "Route not found",
+		"path":   r.URL.Path,
+		"method": r.Method,
+	})
+}
+
+// newRoute compiles the match conditions and response templates of every example of a request
+func (s *MockService) newRoute(req *brunoformat.BrunoRequest, envVars map[string]string) (*route, error) {
+	rt := &route{request: req, env: envVars, sequenceMode: req.Mock.SequenceMode, delay: s.delay}
+	rt.listing = newListing(req.Mock.Inherit(req.FolderMock))
+	if info, err := os.Stat(req.FilePath); err == nil {
+		rt.modified = info.ModTime()
+	}
+
+	if err := rt.setRateLimit(req.Mock.Inherit(req.FolderMock), req.FilePath); err != nil {
+		return nil, fmt.Errorf("%s: %w", req.FilePath, err)
+	}
+
+	// The request's own delay wins over its folders', which win over --delay
+	if spec := req.Mock.Inherit(req.FolderMock).Delay; spec != "" {
+		delay, err := latency.Parse(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", req.FilePath, err)
+		}
+		rt.delay = delay
+	}
+
+	for i := range req.Examples {
+		example := &req.Examples[i]
+
+		conditions, err := compileConditions(example.Match)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := templating.Compile(example.Response.Body.Content, example.Response.Body.IsJSON())
+		if err != nil {
+			return nil, fmt.Errorf("%s: example %q body: %w", req.FilePath, example.Name, err)
+		}
 
-		log.Printf("Registered: %s %s (from %s)", req.Method, path, req.FilePath)
+		headers := make(map[string]*templating.Template, len(example.Response.Headers))
+		for key, value := range example.Response.Headers {
+			tmpl, err := templating.Compile(value, false)
+			if err != nil {
+				return nil, fmt.Errorf("%s: example %q header %s: %w", req.FilePath, example.Name, key, err)
+			}
+			headers[key] = tmpl
+		}
+
+		delay := rt.delay
+		if example.Delay != "" {
+			delay, err = latency.Parse(example.Delay)
+			if err != nil {
+				return nil, fmt.Errorf("%s: example %q: %w", req.FilePath, example.Name, err)
+			}
+		}
+
+		re := &routeExample{
+			example:    example,
+			conditions: conditions,
+			body:       body,
+			headers:    headers,
+			delay:      delay,
+			fault:      faultConfigFrom(example.Fault),
+		}
+		if err := prepareBody(s.baseDir, req, example, re); err != nil {
+			return nil, err
+		}
+		re.mediaType = exampleMediaType(re)
+		rt.examples = append(rt.examples, re)
+	}
+	rt.mediaTypes = routeMediaTypes(rt.examples)
+
+	// Resolve sequence names to compiled examples
+	for _, name := range req.Mock.Sequence {
+		for _, candidate := range rt.examples {
+			if strings.EqualFold(candidate.example.Name, name) {
+				rt.sequence = append(rt.sequence, candidate)
+				break
+			}
+		}
 	}
-	return nil
+
+	return rt, nil
 }
 
 // createHandler creates an HTTP handler for a Bruno request
-func (s *MockService) createHandler(req *brunoformat.BrunoRequest) http.HandlerFunc {
+func (s *MockService) createHandler(rt *route) http.HandlerFunc {
+	req := rt.request
+
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Extract path parameters
-		params := s.extractPathParams(r)
-
-		// Parse the body content from the example block
-		var body interface{}
-		if req.Example.Response.Body.Content != "" {
-			// Unmarshal the body content as JSON
-			if err := json.Unmarshal([]byte(req.Example.Response.Body.Content), &body); err != nil {
-				log.Printf("Warning: failed to parse response body for %s: %v", req.FilePath, err)
-				body = nil
+		info := newRequestInfo(r)
+
+		// The response depends on Accept, if only for a 406
+		w.Header().Add("Vary", "Accept")
+
+		// Pick the example to serve
+		selected, err := s.selectExample(rt, r, info)
+		if errors.Is(err, errNotAcceptable) {
+			response.WriteError(w, http.StatusNotAcceptable, "NOT_ACCEPTABLE", fmt.Sprintf("%s serves none of the accepted media types %q (available: %s)",
+				req.FilePath, r.Header.Get("Accept"), strings.Join(rt.mediaTypes, ", ")))
+			return
+		}
+		if err != nil {
+			response.WriteNotFound(w, fmt.Sprintf("example %q not found in %s (available: %s)",
+				s.requestedExample(r), req.FilePath, strings.Join(req.ExampleNames(), ", ")))
+			return
+		}
+		journalExample(r, selected)
+
+		// Simulate latency; give up if the client goes away meanwhile
+		if !s.wait(r, selected.delay) {
+			return
+		}
+
+		serve := func(w http.ResponseWriter, r *http.Request) {
+			s.writeExample(w, r, rt, selected, info)
+		}
+		if fault := s.exampleFault(r, selected); fault != "" {
+			s.injectFault(w, r, rt.key, fault, *selected.fault, serve)
+			return
+		}
+		serve(w, r)
+	}
+}
+
+// writeExample renders and writes the selected example
+func (s *MockService) writeExample(w http.ResponseWriter, r *http.Request, rt *route, selected *routeExample, info *requestInfo) {
+	example := selected.example
+
+	// Build the template context from the request
+	ctx := &templating.Context{
+		Request: r,
+		Params:  s.extractPathParams(r),
+		Env:     rt.env,
+		Body:    info.bodyData,
+	}
+
+	// Set custom headers from example block
+	for key, tmpl := range selected.headers {
+		w.Header().Set(key, tmpl.Render(ctx))
+	}
+
+	// Set Content-Type from the body type if not already set
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", selected.contentType)
+	}
+
+	// Events and chunks are flushed one at a time
+	if selected.stream != nil {
+		s.writeStream(w, r, selected, ctx)
+		return
+	}
+
+	// Fixtures are streamed from disk
+	if selected.file != "" {
+		s.writeFile(w, r, selected.file, statusCode(example))
+		return
+	}
+
+	// Render the response body
+	body := selected.raw
+	if body == nil {
+		rendered := selected.body.Render(ctx)
+		if rt.listing != nil && example.Response.Body.IsJSON() {
+			listed, err := rt.listing.apply(r, w.Header(), rendered)
+			if err != nil {
+				response.WriteBadRequest(w, err.Error())
+				return
 			}
+			rendered = listed
 		}
+		if rendered != "" {
+			body = s.formatBody(rendered, example)
+		}
+	}
 
-		// Interpolate variables in response body
-		body = s.interpolateVariables(body, params)
+	// Templated bodies may change between requests without the .bru file
+	// changing, so only static ones carry Last-Modified
+	var modified time.Time
+	if selected.raw != nil || selected.body.IsStatic() {
+		modified = rt.modified
+	}
+	encoding := responseEncoding(w, r, int64(len(body)))
+	setValidators(w.Header(), bodyETag(body, encoding), modified)
+	if notModified(w, r, statusCode(example)) {
+		return
+	}
+	if encoding != "" {
+		body = compress(body, encoding)
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	if len(body) > 0 {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	}
 
-		// Set custom headers from example block
-		for key, value := range req.Example.Response.Headers {
-			w.Header().Set(key, value)
+	// Set status code from example block
+	w.WriteHeader(statusCode(example))
+
+	// Write response body
+	w.Write(body)
+}
+
+// wait sleeps for a delay sampled from dist. It reports false if the request
+// was cancelled first, in which case no response should be written.
+func (s *MockService) wait(r *http.Request, dist latency.Distribution) bool {
+	if err := latency.Sleep(r.Context(), dist); err != nil {
+		log.Printf("Client gave up on %s %s during simulated delay: %v", r.Method, r.URL.Path, err)
+		return false
+	}
+	return true
+}
+
+// formatBody compacts valid JSON bodies so responses are independent of file indentation
+func (s *MockService) formatBody(body string, example *brunoformat.ExampleBlock) []byte {
+	if !example.Response.Body.IsJSON() {
+		return []byte(body)
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(body)); err != nil {
+		log.Printf("Warning: response body for %s is not valid JSON: %v", example.Name, err)
+		return []byte(body)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// statusCode returns the example's status code, defaulting to 200 when unset
+func statusCode(example *brunoformat.ExampleBlock) int {
+	if example.Response.Status.Code == 0 {
+		return http.StatusOK
+	}
+	return example.Response.Status.Code
+}
+
+// selectExample picks the example to serve for a request:
+//  1. an example named by the X-Mock-Example header or __example query parameter
+//  2. examples tagged with the request's scenario, if the route has any
+//  3. the route's response sequence, counted per scenario
+//  4. examples with match conditions in file order, falling back to the
+//     first example without conditions
+//
+// In steps 2 and 4, examples of different media types are negotiated by
+// the Accept header; see negotiate. Scenario-tagged examples are only
+// served while their scenario is active. It returns errUnknownExample when
+// a name was given but no example has it, and errNotAcceptable when no
+// candidate has an acceptable media type.
+func (s *MockService) selectExample(rt *route, r *http.Request, info *requestInfo) (*routeExample, error) {
+	if name := s.requestedExample(r); name != "" {
+		for _, candidate := range rt.examples {
+			if strings.EqualFold(candidate.example.Name, name) {
+				return candidate, nil
+			}
 		}
+		return nil, errUnknownExample
+	}
 
-		// Set Content-Type if not already set
-		if w.Header().Get("Content-Type") == "" {
-			w.Header().Set("Content-Type", "application/json")
+	scenario := s.requestedScenario(r)
+	if scenario != "" {
+		if candidates := s.matchExamples(rt, info, scenario); len(candidates) > 0 {
+			return rt.negotiate(r, candidates)
 		}
+	}
 
-		// Set status code from example block
-		w.WriteHeader(req.Example.Response.Status.Code)
+	if len(rt.sequence) > 0 {
+		call := s.scenarios.next(rt.key, scenario)
+		if call >= len(rt.sequence) {
+			if rt.sequenceMode == brunoformat.SequenceRepeat {
+				call %= len(rt.sequence)
+			} else {
+				call = len(rt.sequence) - 1
+			}
+		}
+		return rt.sequence[call], nil
+	}
 
-		// Write response body
-		if body != nil {
-			json.NewEncoder(w).Encode(body)
+	candidates := s.matchExamples(rt, info, "")
+	if len(candidates) == 0 {
+		// Every untagged example has unmet conditions: fall back to all untagged ones
+		for _, candidate := range rt.examples {
+			if candidate.example.Scenario == "" {
+				candidates = append(candidates, candidate)
+			}
 		}
 	}
+	if len(candidates) == 0 {
+		candidates = rt.examples
+	}
+	return rt.negotiate(r, candidates)
+}
+
+// matchExamples returns the examples belonging to a scenario (empty for
+// untagged examples) that may answer a request, best first: those whose
+// conditions hold in file order, then those without conditions. It returns
+// nothing if the scenario has no examples.
+func (s *MockService) matchExamples(rt *route, info *requestInfo, scenario string) []*routeExample {
+	var matched, fallbacks []*routeExample
+	for _, candidate := range rt.examples {
+		if !strings.EqualFold(candidate.example.Scenario, scenario) {
+			continue
+		}
+		if len(candidate.conditions) == 0 {
+			fallbacks = append(fallbacks, candidate)
+			continue
+		}
+		if matchAll(candidate.conditions, info) {
+			matched = append(matched, candidate)
+		}
+	}
+	return append(matched, fallbacks...)
+}
+
+// requestedScenario returns the scenario for a request: the X-Mock-Scenario
+// header if present, otherwise the collection-wide active scenario
+func (s *MockService) requestedScenario(r *http.Request) string {
+	if name := r.Header.Get(ScenarioHeader); name != "" {
+		return name
+	}
+	return s.scenarios.Active()
+}
+
+// requestedExample returns the example name requested by the client, if any
+func (s *MockService) requestedExample(r *http.Request) string {
+	if name := r.Header.Get(ExampleHeader); name != "" {
+		return name
+	}
+	return r.URL.Query().Get(ExampleQueryParam)
 }
 
 // extractPathParams extracts all path parameters from the request using chi
@@ -97,32 +691,3 @@ func (s *MockService) extractPathParams(r *http.Request) map[string]string {
 
 	return params
 }
-
-// interpolateVariables replaces {{varName}} placeholders in the response body
-func (s *MockService) interpolateVariables(body interface{}, vars map[string]string) interface{} {
-	if body == nil {
-		return nil
-	}
-
-	// Convert body to JSON string
-	jsonBytes, err := json.Marshal(body)
-	if err != nil {
-		return body // Return original if we can't marshal
-	}
-
-	jsonStr := string(jsonBytes)
-
-	// Replace {{varName}} with actual values
-	for key, value := range vars {
-		placeholder := "{{" + key + "}}"
-		jsonStr = strings.ReplaceAll(jsonStr, placeholder, value)
-	}
-
-	// Parse back to interface{}
-	var result interface{}
-	if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
-		return body // Return original if we can't unmarshal
-	}
-
-	return result
-}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// errUnknownExample is returned when a request names an example the route does not have
+	errUnknownExample = errors.New("unknown example")
+	// errNotAcceptable is returned when no example has a media type the request accepts
+	errNotAcceptable = errors.New("not acceptable")
+)
+
+// mediaRange is an entry of an Accept header, e.g. text/* with q=0.5
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept parses an Accept header; entries with an invalid quality are ignored
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, entry := range strings.Split(header, ",") {
+		params := strings.Split(entry, ";")
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(params[0])), "/")
+		if !ok {
+			continue
+		}
+
+		mr := mediaRange{typ: typ, subtype: subtype, q: 1}
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				q, err := strconv.ParseFloat(value, 64)
+				if err != nil || q < 0 || q > 1 {
+					ok = false
+				}
+				mr.q = q
+			}
+		}
+		if ok {
+			ranges = append(ranges, mr)
+		}
+	}
+	return ranges
+}
+
+// quality returns how much the ranges accept a media type: the q value of
+// the most specific matching range, 0 if none matches, and 1 without ranges
+func quality(ranges []mediaRange, mediaType string) float64 {
+	if len(ranges) == 0 {
+		return 1
+	}
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+
+	q, specificity := 0.0, -1
+	for _, mr := range ranges {
+		s := -1
+		switch {
+		case mr.typ == typ && mr.subtype == subtype:
+			s = 2
+		case mr.typ == typ && mr.subtype == "*":
+			s = 1
+		case mr.typ == "*" && mr.subtype == "*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = mr.q, s
+		}
+	}
+	return q
+}
+
+// exampleMediaType returns the media type an example is served with: that
+// of its Content-Type header unless the header is templated, otherwise the
+// default for its body type
+func exampleMediaType(re *routeExample) string {
+	contentType := re.contentType
+	for key, value := range re.example.Response.Headers {
+		if strings.EqualFold(key, "Content-Type") && !strings.Contains(value, "{{") {
+			contentType = value
+		}
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+// routeMediaTypes returns the distinct media types of a route's examples
+func routeMediaTypes(examples []*routeExample) []string {
+	var types []string
+	for _, re := range examples {
+		if !slices.Contains(types, re.mediaType) {
+			types = append(types, re.mediaType)
+		}
+	}
+	return types
+}
+
+// negotiate picks the candidate whose media type the request's Accept header
+// prefers, keeping the candidates' order among equally acceptable ones.
+// Without an Accept header every candidate is acceptable.
+func (rt *route) negotiate(r *http.Request, candidates []*routeExample) (*routeExample, error) {
+	ranges := parseAccept(r.Header.Get("Accept"))
+	var best *routeExample
+	bestQ := 0.0
+	for _, candidate := range candidates {
+		if q := quality(ranges, candidate.mediaType); q > bestQ {
+			best, bestQ = candidate, q
+		}
+	}
+	if best == nil {
+		return nil, errNotAcceptable
+	}
+	return best, nil
+}
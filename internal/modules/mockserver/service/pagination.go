@@ -0,0 +1,210 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/anu-mdl/linker-bruno/internal/shared/brunoformat"
+)
+
+// DefaultPageSize is the page size of paginated routes without a pageSize
+const DefaultPageSize = 10
+
+// pagination pages the JSON array bodies of a route
+type pagination struct {
+	style    string // brunoformat.PaginatePage, PaginateOffset or PaginateCursor
+	size     int
+	envelope bool
+}
+
+// newPagination returns the pagination configured by mock settings, or nil
+func newPagination(settings brunoformat.MockSettings) *pagination {
+	if settings.Paginate == "" {
+		return nil
+	}
+	p := &pagination{
+		style:    settings.Paginate,
+		size:     settings.PageSize,
+		envelope: settings.PageFormat == brunoformat.PageFormatEnvelope,
+	}
+	if p.size == 0 {
+		p.size = DefaultPageSize
+	}
+	return p
+}
+
+// page is the position of a page within an array
+type page struct {
+	start, end, limit, total int
+	number                   int // requested page number for PaginatePage
+}
+
+// apply returns the requested page of the items of an array body as JSON,
+// setting the Link and X-Total-Count headers or wrapping the page in an
+// envelope. An invalid page request is returned as an error for a 400 response.
+func (p *pagination) apply(r *http.Request, header http.Header, items []json.RawMessage) (string, error) {
+	pg, err := p.locate(r.URL.Query(), len(items))
+	if err != nil {
+		return "", err
+	}
+	items = items[pg.start:pg.end]
+
+	if !p.envelope {
+		header.Set("X-Total-Count", strconv.Itoa(pg.total))
+		if links := p.links(r, pg); len(links) > 0 {
+			header.Set("Link", strings.Join(links, ", "))
+		}
+		data, err := json.Marshal(items)
+		return string(data), err
+	}
+
+	info := map[string]interface{}{"limit": pg.limit, "total": pg.total}
+	switch p.style {
+	case brunoformat.PaginatePage:
+		info["page"] = pg.number
+		info["totalPages"] = pageCount(pg)
+	case brunoformat.PaginateOffset:
+		info["offset"] = pg.start
+	case brunoformat.PaginateCursor:
+		info["nextCursor"] = nil
+		if pg.end < pg.total {
+			info["nextCursor"] = encodeCursor(pg.end)
+		}
+	}
+	data, err := json.Marshal(map[string]interface{}{"data": items, "pagination": info})
+	return string(data), err
+}
+
+// locate reads the page a request asks for from its query parameters
+func (p *pagination) locate(query url.Values, total int) (page, error) {
+	pg := page{limit: p.size, total: total}
+
+	var err error
+	if pg.limit, err = queryInt(query, "limit", p.size, 1); err != nil {
+		return pg, err
+	}
+
+	switch p.style {
+	case brunoformat.PaginatePage:
+		if pg.number, err = queryInt(query, "page", 1, 1); err != nil {
+			return pg, err
+		}
+		// Avoid overflowing for absurd page numbers
+		pg.start = total
+		if pg.number-1 <= total/pg.limit {
+			pg.start = (pg.number - 1) * pg.limit
+		}
+	case brunoformat.PaginateOffset:
+		if pg.start, err = queryInt(query, "offset", 0, 0); err != nil {
+			return pg, err
+		}
+	case brunoformat.PaginateCursor:
+		if cursor := query.Get("cursor"); cursor != "" {
+			if pg.start, err = decodeCursor(cursor); err != nil {
+				return pg, err
+			}
+		}
+	}
+
+	pg.start = min(pg.start, total)
+	pg.end = pg.start + min(pg.limit, total-pg.start)
+	return pg, nil
+}
+
+// links returns the Link header entries for the pages around pg
+func (p *pagination) links(r *http.Request, pg page) []string {
+	var links []string
+	link := func(rel string, params map[string]string) {
+		links = append(links, fmt.Sprintf("<%s>; rel=%q", pageURL(r, params), rel))
+	}
+	limit := strconv.Itoa(pg.limit)
+	lastStart := (pageCount(pg) - 1) * pg.limit
+
+	switch p.style {
+	case brunoformat.PaginatePage:
+		link("first", map[string]string{"page": "1", "limit": limit})
+		if pg.number > 1 {
+			link("prev", map[string]string{"page": strconv.Itoa(min(pg.number-1, pageCount(pg))), "limit": limit})
+		}
+		if pg.end < pg.total {
+			link("next", map[string]string{"page": strconv.Itoa(pg.number + 1), "limit": limit})
+		}
+		link("last", map[string]string{"page": strconv.Itoa(pageCount(pg)), "limit": limit})
+	case brunoformat.PaginateOffset:
+		link("first", map[string]string{"offset": "0", "limit": limit})
+		if pg.start > 0 {
+			link("prev", map[string]string{"offset": strconv.Itoa(max(pg.start-pg.limit, 0)), "limit": limit})
+		}
+		if pg.end < pg.total {
+			link("next", map[string]string{"offset": strconv.Itoa(pg.end), "limit": limit})
+		}
+		link("last", map[string]string{"offset": strconv.Itoa(lastStart), "limit": limit})
+	case brunoformat.PaginateCursor:
+		link("first", map[string]string{"cursor": "", "limit": limit})
+		if pg.end < pg.total {
+			link("next", map[string]string{"cursor": encodeCursor(pg.end), "limit": limit})
+		}
+	}
+	return links
+}
+
+// pageCount returns the number of pages, counting an empty array as one page
+func pageCount(pg page) int {
+	return max((pg.total+pg.limit-1)/pg.limit, 1)
+}
+
+// pageURL returns the absolute URL of the request with query parameters
+// replaced; empty values remove the parameter
+func pageURL(r *http.Request, params map[string]string) string {
+	query := r.URL.Query()
+	for key, value := range params {
+		if value == "" {
+			query.Del(key)
+		} else {
+			query.Set(key, value)
+		}
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	u := url.URL{Scheme: scheme, Host: r.Host, Path: r.URL.Path, RawQuery: query.Encode()}
+	return u.String()
+}
+
+// queryInt reads an integer query parameter of at least minimum
+func queryInt(query url.Values, name string, fallback, minimum int) (int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < minimum {
+		return 0, fmt.Errorf("invalid %s %q: expected an integer of at least %d", name, value, minimum)
+	}
+	return n, nil
+}
+
+// encodeCursor returns the opaque cursor for the item at index
+func encodeCursor(index int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(index)))
+}
+
+// decodeCursor returns the item index of a cursor from encodeCursor
+func decodeCursor(cursor string) (int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		if value, ok := strings.CutPrefix(string(data), "offset:"); ok {
+			if index, err := strconv.Atoi(value); err == nil && index >= 0 {
+				return index, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid cursor %q", cursor)
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/anu-mdl/linker-bruno/internal/shared/response"
+)
+
+// newProxy creates a reverse proxy to the upstream. Headers and bodies are
+// passed through unchanged and responses are flushed as they arrive, so
+// streaming endpoints keep working.
+func newProxy(target *url.URL) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.SetXForwarded()
+		},
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("Proxy error for %s %s: %v", r.Method, r.URL.Path, err)
+			response.WriteError(w, http.StatusBadGateway, "BAD_GATEWAY", "upstream request failed: "+err.Error())
+		},
+	}
+}
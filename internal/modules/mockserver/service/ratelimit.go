@@ -0,0 +1,61 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/anu-mdl/linker-bruno/internal/shared/brunoformat"
+	"github.com/anu-mdl/linker-bruno/internal/shared/middleware"
+)
+
+// setRateLimit configures the rate limit of a route from its mock settings.
+// A limit set in a folder.bru is shared by all requests of the folder; one
+// set in the request file counts the route's requests alone.
+func (rt *route) setRateLimit(settings brunoformat.MockSettings, filePath string) error {
+	if settings.RateLimit == "" {
+		return nil
+	}
+	limit, err := middleware.ParseRateLimit(settings.RateLimit, settings.RateLimitKey, settings.RateLimitAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	rt.rateLimit = &limit
+	rt.rateScope = filePath
+	if settings.RateLimitFolder != "" {
+		rt.rateScope = settings.RateLimitFolder
+	}
+	return nil
+}
+
+// createRateLimitHandler counts requests against the route's rate limit,
+// adding X-RateLimit-* headers to its responses. Requests over the limit
+// get the route's first 429 example if it has one, else a default 429.
+func (s *MockService) createRateLimitHandler(rt *route, next http.HandlerFunc) http.HandlerFunc {
+	if rt.rateLimit == nil {
+		return next
+	}
+
+	var limited *routeExample
+	for _, re := range rt.examples {
+		if statusCode(re.example) == http.StatusTooManyRequests {
+			limited = re
+			break
+		}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		st := s.limiter.Allow(rt.rateScope, *rt.rateLimit, r)
+		st.SetHeaders(w.Header())
+		if st.Allowed {
+			next(w, r)
+			return
+		}
+
+		if limited == nil {
+			middleware.WriteTooManyRequests(w, st)
+			return
+		}
+		journalExample(r, limited)
+		s.writeExample(w, r, rt, limited, newRequestInfo(r))
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/anu-mdl/linker-bruno/internal/shared/brunoformat"
+	"github.com/anu-mdl/linker-bruno/internal/shared/jsonpath"
+)
+
+// maxBodyBytes caps how much of a request body is buffered for matching
+const maxBodyBytes = 10 << 20
+
+// requestInfo gives match rules and templates access to an incoming request.
+// The body is read and decoded on first use and then restored on the
+// request so later handlers can still read it.
+type requestInfo struct {
+	r          *http.Request
+	bodyRead   bool
+	rawBody    []byte
+	body       interface{}
+	bodyIsJSON bool
+}
+
+// newRequestInfo wraps an incoming request
+func newRequestInfo(r *http.Request) *requestInfo {
+	return &requestInfo{r: r}
+}
+
+// lookup returns the value addressed by a match source and key
+func (i *requestInfo) lookup(source, key string) (string, bool) {
+	switch source {
+	case brunoformat.MatchQuery:
+		values, ok := i.r.URL.Query()[key]
+		if !ok || len(values) == 0 {
+			return "", false
+		}
+		return values[0], true
+	case brunoformat.MatchHeaders:
+		values := i.r.Header.Values(key)
+		if len(values) == 0 {
+			return "", false
+		}
+		return values[0], true
+	case brunoformat.MatchBody:
+		body, ok := i.jsonBody()
+		if !ok {
+			return "", false
+		}
+		value, ok := jsonpath.Lookup(body, key)
+		if !ok {
+			return "", false
+		}
+		return jsonpath.Stringify(value), true
+	}
+	return "", false
+}
+
+// jsonBody returns the decoded JSON request body, reporting false if it is not JSON
+func (i *requestInfo) jsonBody() (interface{}, bool) {
+	i.readBody()
+	return i.body, i.bodyIsJSON
+}
+
+// bodyData exposes the buffered body to response templates
+func (i *requestInfo) bodyData() ([]byte, interface{}, bool) {
+	i.readBody()
+	return i.rawBody, i.body, i.bodyIsJSON
+}
+
+// readBody buffers the request body once and puts it back on the request
+func (i *requestInfo) readBody() {
+	if i.bodyRead {
+		return
+	}
+	i.bodyRead = true
+
+	if i.r.Body == nil {
+		return
+	}
+
+	// Anything past the limit stays unread for handlers that forward or
+	// store the body, such as the proxy and stateful routes
+	data, err := io.ReadAll(io.LimitReader(i.r.Body, maxBodyBytes))
+	i.r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), i.r.Body), i.r.Body}
+	if err != nil {
+		return
+	}
+
+	i.rawBody = data
+	if len(bytes.TrimSpace(data)) > 0 && json.Unmarshal(data, &i.body) == nil {
+		i.bodyIsJSON = true
+	}
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadBodyKeepsRequestBody(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{name: "small", size: 16},
+		{name: "over the buffer limit", size: maxBodyBytes + 1024},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := strings.Repeat("x", tt.size)
+			r := httptest.NewRequest("POST", "/upload", strings.NewReader(body))
+			info := newRequestInfo(r)
+
+			raw, _, _ := info.bodyData()
+			if want := min(tt.size, maxBodyBytes); len(raw) != want {
+				t.Errorf("buffered %d bytes, want %d", len(raw), want)
+			}
+			forwarded, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Fatalf("reading body: %v", err)
+			}
+			if len(forwarded) != tt.size {
+				t.Errorf("request body has %d bytes, want %d", len(forwarded), tt.size)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"sort"
+	"sync"
+)
+
+// ScenarioHeader selects a scenario for a single request, overriding the active one
+const ScenarioHeader = "X-Mock-Scenario"
+
+// ScenarioState tracks the active scenario and per-route call counters for
+// response sequences. Counters are kept separately for every scenario, so
+// test suites that each send their own X-Mock-Scenario do not interfere.
+type ScenarioState struct {
+	mu       sync.Mutex
+	active   string
+	known    []string
+	counters map[counterKey]int
+}
+
+// counterKey identifies a sequence counter
+type counterKey struct {
+	route    string
+	scenario string
+}
+
+// ScenarioCounter reports how often a route has been called within a scenario
+type ScenarioCounter struct {
+	Route    string `json:"route"`
+	Scenario string `json:"scenario"`
+	Calls    int    `json:"calls"`
+}
+
+// NewScenarioState creates a ScenarioState with no active scenario
+func NewScenarioState() *ScenarioState {
+	return &ScenarioState{
+		counters: make(map[counterKey]int),
+	}
+}
+
+// Active returns the collection-wide active scenario; empty means none
+func (st *ScenarioState) Active() string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.active
+}
+
+// SetActive switches the collection-wide scenario; an empty name clears it
+func (st *ScenarioState) SetActive(name string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.active = name
+}
+
+// Known returns the scenario names declared by examples in the collection
+func (st *ScenarioState) Known() []string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return append([]string(nil), st.known...)
+}
+
+// setKnown records the scenario names found while building routes
+func (st *ScenarioState) setKnown(names map[string]bool) {
+	known := make([]string, 0, len(names))
+	for name := range names {
+		known = append(known, name)
+	}
+	sort.Strings(known)
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.known = known
+}
+
+// next returns the zero-based call number for a route within a scenario and advances it
+func (st *ScenarioState) next(route, scenario string) int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	key := counterKey{route: route, scenario: scenario}
+	call := st.counters[key]
+	st.counters[key] = call + 1
+	return call
+}
+
+// Counters returns all sequence counters sorted by route and scenario
+func (st *ScenarioState) Counters() []ScenarioCounter {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	counters := make([]ScenarioCounter, 0, len(st.counters))
+	for key, calls := range st.counters {
+		counters = append(counters, ScenarioCounter{Route: key.route, Scenario: key.scenario, Calls: calls})
+	}
+	sort.Slice(counters, func(i, j int) bool {
+		if counters[i].Route != counters[j].Route {
+			return counters[i].Route < counters[j].Route
+		}
+		return counters[i].Scenario < counters[j].Scenario
+	})
+	return counters
+}
+
+// ResetCounters restarts every sequence from its first example.
+// With a scenario name only that scenario's counters are reset.
+func (st *ScenarioState) ResetCounters(scenario string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	for key := range st.counters {
+		if scenario == "" || key.scenario == scenario {
+			delete(st.counters, key)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/anu-mdl/linker-bruno/internal/shared/brunoformat"
+	"github.com/anu-mdl/linker-bruno/internal/shared/response"
+	"github.com/go-chi/chi/v5"
+)
+
+// crudBinding ties a route to an operation on a stateful resource
+type crudBinding struct {
+	resource string // collection path, e.g. "/users"
+	key      string // store key of the resource; see resourceKey
+	param    string // path parameter holding the item id; empty for collection routes
+}
+
+// detectResources finds collection paths that have a GET route whose default
+// example is a JSON array and seeds the store with them for the environment
+// and host. It returns the store keys of the resources by collection path.
+func (s *MockService) detectResources(requests []*brunoformat.BrunoRequest, paths []string, envName, host string) map[string]string {
+	resources := make(map[string]string)
+
+	for i, req := range requests {
+		if req.Method != http.MethodGet {
+			continue
+		}
+		seed := strings.TrimSpace(req.DefaultExample().Response.Body.Content)
+		if !strings.HasPrefix(seed, "[") {
+			continue
+		}
+		key := resourceKey(envName, host, paths[i])
+		if err := s.store.Seed(key, seed); err != nil {
+			log.Printf("Warning: not emulating %s as a resource (%s): %v", paths[i], req.FilePath, err)
+			continue
+		}
+		resources[paths[i]] = key
+	}
+
+	return resources
+}
+
+// bindResource returns the stateful operation a route maps to, if any
+func (s *MockService) bindResource(method, path string, resources map[string]string) (crudBinding, bool) {
+	if key, ok := resources[path]; ok && (method == http.MethodGet || method == http.MethodPost) {
+		return crudBinding{resource: path, key: key}, true
+	}
+
+	slash := strings.LastIndex(path, "/")
+	if slash <= 0 {
+		return crudBinding{}, false
+	}
+	parent, last := path[:slash], path[slash+1:]
+	key, ok := resources[parent]
+	if !ok || !strings.HasPrefix(last, "{") || !strings.HasSuffix(last, "}") {
+		return crudBinding{}, false
+	}
+
+	switch method {
+	case http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		// Strip an optional chi regex: {id:[0-9]+}
+		param, _, _ := strings.Cut(strings.Trim(last, "{}"), ":")
+		return crudBinding{resource: parent, key: key, param: param}, true
+	}
+	return crudBinding{}, false
+}
+
+// createStatefulHandler serves a route from the resource store. Explicitly
+// selecting an example with X-Mock-Example still serves the static example.
+// Lists are filtered, sorted and paged like example bodies.
+func (s *MockService) createStatefulHandler(binding crudBinding, rt *route, static http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.requestedExample(r) != "" {
+			static(w, r)
+			return
+		}
+
+		if !s.wait(r, rt.delay) {
+			return
+		}
+
+		// Collection routes
+		if binding.param == "" {
+			if r.Method == http.MethodGet {
+				writeList(w, r, rt, s.store.List(binding.key))
+				return
+			}
+
+			item, ok := decodeItem(w, r)
+			if !ok {
+				return
+			}
+			created, err := s.store.Create(binding.key, item)
+			if errors.Is(err, errItemExists) {
+				response.WriteError(w, http.StatusConflict, "CONFLICT", err.Error()+" in "+binding.resource)
+				return
+			}
+			if err != nil {
+				response.WriteNotFound(w, err.Error())
+				return
+			}
+			writeJSON(w, http.StatusCreated, created)
+			return
+		}
+
+		// Item routes
+		id := chi.URLParam(r, binding.param)
+		var result map[string]interface{}
+		found := false
+
+		switch r.Method {
+		case http.MethodGet:
+			result, found = s.store.Get(binding.key, id)
+		case http.MethodDelete:
+			if s.store.Delete(binding.key, id) {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		case http.MethodPut, http.MethodPatch:
+			item, ok := decodeItem(w, r)
+			if !ok {
+				return
+			}
+			if r.Method == http.MethodPut {
+				result, found = s.store.Replace(binding.key, id, item)
+			} else {
+				result, found = s.store.Patch(binding.key, id, item)
+			}
+		}
+
+		if !found {
+			response.WriteNotFound(w, "no item with "+idField+" "+id+" in "+binding.resource)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// decodeItem reads a JSON object from the request body, writing a 400 on failure
+func decodeItem(w http.ResponseWriter, r *http.Request) (map[string]interface{}, bool) {
+	var item map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil || item == nil {
+		response.WriteBadRequest(w, "request body must be a JSON object")
+		return nil, false
+	}
+	return item, true
+}
+
+// writeList writes the items of a resource, filtered, sorted and paged if the route says so
+func writeList(w http.ResponseWriter, r *http.Request, rt *route, items []map[string]interface{}) {
+	if rt.listing == nil {
+		writeJSON(w, http.StatusOK, items)
+		return
+	}
+
+	data, _ := json.Marshal(items)
+	body, err := rt.listing.apply(r, w.Header(), string(data))
+	if err != nil {
+		response.WriteBadRequest(w, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, body+"\n")
+}
+
+// writeJSON writes v as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
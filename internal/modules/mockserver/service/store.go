@@ -0,0 +1,261 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/anu-mdl/linker-bruno/internal/shared/jsonpath"
+)
+
+// idField is the item property used to address resources by path parameter
+const idField = "id"
+
+// errItemExists is returned when creating an item whose id is already taken
+var errItemExists = errors.New("item already exists")
+
+// ResourceStore keeps in-memory collections for stateful CRUD emulation.
+// Each resource is keyed by environment, host and collection path (see
+// resourceKey) and seeded from the JSON array in the list route's default
+// example.
+type ResourceStore struct {
+	mu        sync.Mutex
+	resources map[string]*resource
+}
+
+// resource is a single in-memory collection
+type resource struct {
+	seed   string // original JSON array, used for reset and change detection
+	items  []map[string]interface{}
+	nextID int
+}
+
+// resourceKey identifies a resource, e.g. "dev api.example.com/users";
+// routes without a host have an empty host, e.g. "dev /users"
+func resourceKey(envName, host, path string) string {
+	return envName + " " + host + path
+}
+
+// NewResourceStore creates an empty ResourceStore
+func NewResourceStore() *ResourceStore {
+	return &ResourceStore{
+		resources: make(map[string]*resource),
+	}
+}
+
+// Seed registers a resource with its initial items. An existing resource
+// keeps its current state unless the seed itself has changed.
+func (st *ResourceStore) Seed(key, seed string) error {
+	items, err := decodeItems(seed)
+	if err != nil {
+		return err
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if existing, ok := st.resources[key]; ok && existing.seed == seed {
+		return nil
+	}
+	st.resources[key] = newResource(seed, items)
+	return nil
+}
+
+// List returns all items of a resource
+func (st *ResourceStore) List(key string) []map[string]interface{} {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	res, ok := st.resources[key]
+	if !ok {
+		return nil
+	}
+	items := make([]map[string]interface{}, len(res.items))
+	for i, item := range res.items {
+		items[i] = maps.Clone(item)
+	}
+	return items
+}
+
+// Get returns the item with the given id
+func (st *ResourceStore) Get(key, id string) (map[string]interface{}, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	res, index := st.find(key, id)
+	if index < 0 {
+		return nil, false
+	}
+	return maps.Clone(res.items[index]), true
+}
+
+// Create adds an item, assigning an id if it has none. An id that is
+// already taken fails with errItemExists.
+func (st *ResourceStore) Create(key string, item map[string]interface{}) (map[string]interface{}, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	res, ok := st.resources[key]
+	if !ok {
+		return nil, fmt.Errorf("no resource %s", key)
+	}
+
+	item = maps.Clone(item)
+	if id, hasID := item[idField]; !hasID {
+		item[idField] = res.newID()
+	} else if _, index := st.find(key, jsonpath.Stringify(id)); index >= 0 {
+		return nil, fmt.Errorf("%w: %s %s", errItemExists, idField, jsonpath.Stringify(id))
+	}
+	res.observeID(item[idField])
+	res.items = append(res.items, item)
+	return maps.Clone(item), nil
+}
+
+// Replace swaps the item with the given id for a new one, keeping the id
+func (st *ResourceStore) Replace(key, id string, item map[string]interface{}) (map[string]interface{}, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	res, index := st.find(key, id)
+	if index < 0 {
+		return nil, false
+	}
+
+	item = maps.Clone(item)
+	item[idField] = res.items[index][idField]
+	res.items[index] = item
+	return maps.Clone(item), true
+}
+
+// Patch merges fields into the item with the given id
+func (st *ResourceStore) Patch(key, id string, fields map[string]interface{}) (map[string]interface{}, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	res, index := st.find(key, id)
+	if index < 0 {
+		return nil, false
+	}
+
+	item := maps.Clone(res.items[index])
+	for key, value := range fields {
+		if key != idField {
+			item[key] = value
+		}
+	}
+	res.items[index] = item
+	return maps.Clone(item), true
+}
+
+// Delete removes the item with the given id
+func (st *ResourceStore) Delete(key, id string) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	res, index := st.find(key, id)
+	if index < 0 {
+		return false
+	}
+	res.items = append(res.items[:index], res.items[index+1:]...)
+	return true
+}
+
+// Reset restores every resource to its seed data
+func (st *ResourceStore) Reset() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	for key, res := range st.resources {
+		items, _ := decodeItems(res.seed)
+		st.resources[key] = newResource(res.seed, items)
+	}
+}
+
+// Snapshot returns a copy of all resources by key
+func (st *ResourceStore) Snapshot() map[string][]map[string]interface{} {
+	st.mu.Lock()
+	keys := make([]string, 0, len(st.resources))
+	for key := range st.resources {
+		keys = append(keys, key)
+	}
+	st.mu.Unlock()
+
+	sort.Strings(keys)
+	snapshot := make(map[string][]map[string]interface{}, len(keys))
+	for _, key := range keys {
+		snapshot[key] = st.List(key)
+	}
+	return snapshot
+}
+
+// find locates an item by id; the returned index is -1 when it does not exist
+func (st *ResourceStore) find(key, id string) (*resource, int) {
+	res, ok := st.resources[key]
+	if !ok {
+		return nil, -1
+	}
+	for i, item := range res.items {
+		if jsonpath.Stringify(item[idField]) == id {
+			return res, i
+		}
+	}
+	return res, -1
+}
+
+// newResource creates a resource from decoded seed items
+func newResource(seed string, items []map[string]interface{}) *resource {
+	res := &resource{seed: seed, items: items}
+	for _, item := range items {
+		res.observeID(item[idField])
+	}
+	return res
+}
+
+// observeID keeps nextID above every numeric id seen so far
+func (res *resource) observeID(id interface{}) {
+	var n int
+	switch v := id.(type) {
+	case float64:
+		n = int(v)
+	case string:
+		parsed, err := strconv.Atoi(v)
+		if err != nil {
+			return
+		}
+		n = parsed
+	default:
+		return
+	}
+	if n >= res.nextID {
+		res.nextID = n + 1
+	}
+}
+
+// newID returns the next id, numeric if the seed used numeric ids
+func (res *resource) newID() interface{} {
+	if res.nextID == 0 {
+		res.nextID = 1
+	}
+	id := res.nextID
+	res.nextID++
+
+	for _, item := range res.items {
+		if _, isString := item[idField].(string); isString {
+			return strconv.Itoa(id)
+		}
+	}
+	return float64(id)
+}
+
+// decodeItems parses a JSON array of objects
+func decodeItems(seed string) ([]map[string]interface{}, error) {
+	var items []map[string]interface{}
+	if err := json.Unmarshal([]byte(seed), &items); err != nil {
+		return nil, fmt.Errorf("seed is not a JSON array of objects: %w", err)
+	}
+	return items, nil
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResourceStoreCreate(t *testing.T) {
+	tests := []struct {
+		name   string
+		seed   string
+		item   map[string]interface{}
+		wantID interface{}
+		err    error
+	}{
+		{name: "assigns numeric id", seed: `[{"id": 1}, {"id": 7}]`, item: map[string]interface{}{"name": "new"}, wantID: float64(8)},
+		{name: "assigns string id", seed: `[{"id": "3"}]`, item: map[string]interface{}{"name": "new"}, wantID: "4"},
+		{name: "keeps given id", seed: `[{"id": 1}]`, item: map[string]interface{}{"id": float64(5)}, wantID: float64(5)},
+		{name: "duplicate id", seed: `[{"id": 1}]`, item: map[string]interface{}{"id": float64(1)}, err: errItemExists},
+		{name: "duplicate id of another type", seed: `[{"id": 1}]`, item: map[string]interface{}{"id": "1"}, err: errItemExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := NewResourceStore()
+			key := resourceKey("dev", "", "/users")
+			if err := st.Seed(key, tt.seed); err != nil {
+				t.Fatalf("Seed: %v", err)
+			}
+
+			created, err := st.Create(key, tt.item)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error = %v, want %v", err, tt.err)
+				}
+				if n := len(st.List(key)); n != 1 {
+					t.Errorf("store has %d items after a rejected create, want 1", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if created[idField] != tt.wantID {
+				t.Errorf("id = %#v, want %#v", created[idField], tt.wantID)
+			}
+		})
+	}
+}
+
+func TestResourceStoreKeys(t *testing.T) {
+	st := NewResourceStore()
+	a, b := resourceKey("dev", "a.test", "/users"), resourceKey("dev", "b.test", "/users")
+	for _, key := range []string{a, b} {
+		if err := st.Seed(key, `[{"id": 1}]`); err != nil {
+			t.Fatalf("Seed: %v", err)
+		}
+	}
+
+	if _, err := st.Create(a, map[string]interface{}{"id": float64(2)}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if n := len(st.List(b)); n != 1 {
+		t.Errorf("other host has %d items, want 1", n)
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/anu-mdl/linker-bruno/internal/modules/mockserver/latency"
+	"github.com/anu-mdl/linker-bruno/internal/modules/mockserver/templating"
+	"github.com/anu-mdl/linker-bruno/internal/shared/brunoformat"
+)
+
+// streamChunk is an event of an sse body or a chunk of a stream body
+type streamChunk struct {
+	delay latency.Distribution // waited before sending the chunk; nil for none
+	body  *templating.Template
+}
+
+// parseStream splits the content of an sse or stream body into chunks.
+// Chunks are separated by blank lines, and a "delay: <spec>" line in a
+// chunk sets how long to wait before sending it. Each chunk is a template
+// of its own, rendered when it is sent; chunks holding a JSON object or
+// array are templated like JSON bodies.
+func parseStream(content string) ([]streamChunk, error) {
+	var chunks []streamChunk
+	var lines []string
+	var delay latency.Distribution
+
+	flush := func() error {
+		if len(lines) == 0 {
+			if delay != nil {
+				return fmt.Errorf("delay without an event or chunk to send")
+			}
+			return nil
+		}
+		text := strings.Join(lines, "\n")
+		tmpl, err := templating.Compile(text, looksLikeJSON(text))
+		if err != nil {
+			return err
+		}
+		chunks = append(chunks, streamChunk{delay: delay, body: tmpl})
+		lines, delay = nil, nil
+		return nil
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if spec, ok := strings.CutPrefix(line, "delay:"); ok {
+			dist, err := latency.Parse(strings.TrimSpace(spec))
+			if err != nil {
+				return nil, err
+			}
+			delay = dist
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("no events or chunks to send")
+	}
+	return chunks, nil
+}
+
+// looksLikeJSON reports whether a chunk, or the data of an event, is a JSON
+// object or array
+func looksLikeJSON(text string) bool {
+	text = strings.TrimSpace(text)
+	if data, ok := strings.CutPrefix(text, "data:"); ok {
+		text = strings.TrimSpace(data)
+	} else if _, data, ok := strings.Cut(text, "\ndata:"); ok {
+		text = strings.TrimSpace(data)
+	}
+	return strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[")
+}
+
+// writeStream sends the chunks of an sse or stream body one at a time,
+// flushing each so the client sees it as soon as it is sent. Events end
+// with a blank line and chunks with a line break. Without a Content-Length,
+// HTTP/1.1 responses use chunked transfer encoding.
+func (s *MockService) writeStream(w http.ResponseWriter, r *http.Request, selected *routeExample, ctx *templating.Context) {
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.WriteHeader(statusCode(selected.example))
+
+	end := "\n"
+	if selected.example.Response.Body.Type == brunoformat.BodySSE {
+		end = "\n\n"
+	}
+
+	rc := http.NewResponseController(w)
+	rc.Flush()
+	for i, chunk := range selected.stream {
+		if err := latency.Sleep(r.Context(), chunk.delay); err != nil {
+			log.Printf("Client closed %s %s after %d of %d chunks: %v", r.Method, r.URL.Path, i, len(selected.stream), err)
+			return
+		}
+		if _, err := io.WriteString(w, chunk.body.Render(ctx)+end); err != nil {
+			return
+		}
+		rc.Flush()
+	}
+}
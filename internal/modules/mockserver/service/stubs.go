@@ -0,0 +1,343 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/anu-mdl/linker-bruno/internal/shared/brunoformat"
+	"github.com/go-chi/chi/v5"
+)
+
+// Stub is a route added at runtime through the admin API. Stubs take
+// precedence over routes loaded from .bru files and survive reloads.
+type Stub struct {
+	ID        string                    `json:"id"`
+	Route     string                    `json:"route"`               // method and chi pattern
+	Request   *brunoformat.BrunoRequest `json:"request"`             // normalized as if loaded from a .bru file
+	Persisted string                    `json:"persisted,omitempty"` // .bru file the stub was written to
+}
+
+// ErrStubFileExists is returned when persisting a stub would overwrite a file
+// the stub did not write, such as a hand-written .bru file
+var ErrStubFileExists = errors.New("file already exists")
+
+// Environment is a named set of environment variables
+type Environment struct {
+	Name string
+	Vars map[string]string
+	// Hosts are named by the URLs of the environment's routes; stubs are
+	// dispatched by host like those routes
+	Hosts []string
+}
+
+// StubState holds the runtime stubs and the routers built from them
+type StubState struct {
+	mu     sync.Mutex
+	stubs  []*Stub // in the order they were added
+	nextID int
+	envs   []Environment // environments of the current route tables, default first
+
+	routers atomic.Pointer[map[string]*hostRouter] // router per environment name; nil without stubs
+}
+
+// NewStubState creates a StubState without stubs
+func NewStubState() *StubState {
+	return &StubState{}
+}
+
+// Stubs returns all stubs in the order they were added
+func (s *MockService) Stubs() []*Stub {
+	s.stubs.mu.Lock()
+	defer s.stubs.mu.Unlock()
+	return append(make([]*Stub, 0, len(s.stubs.stubs)), s.stubs.stubs...)
+}
+
+// Stub returns the stub with the given ID
+func (s *MockService) Stub(id string) (*Stub, bool) {
+	s.stubs.mu.Lock()
+	defer s.stubs.mu.Unlock()
+	return s.stub(id)
+}
+
+// stub looks up a stub by ID; stubs.mu must be held
+func (s *MockService) stub(id string) (*Stub, bool) {
+	if i := s.stubs.index(id); i >= 0 {
+		return s.stubs.stubs[i], true
+	}
+	return nil, false
+}
+
+// PutStub adds a stub, or replaces the stub with the same ID. An empty ID
+// generates one. With persist, the stub is also written to a .bru file in
+// the collection: req.FilePath relative to the collection, or a path derived
+// from the URL like files created in the web UI. Only a file written by the
+// same stub is overwritten; any other existing file fails with
+// ErrStubFileExists. It reports whether the stub is new.
+func (s *MockService) PutStub(id string, req *brunoformat.BrunoRequest, persist bool) (*Stub, bool, error) {
+	req.Method = strings.ToUpper(req.Method)
+	if strings.TrimSpace(req.URL) == "" {
+		return nil, false, fmt.Errorf("url is required")
+	}
+	if err := validateStub(req, persist); err != nil {
+		return nil, false, err
+	}
+	if req.Meta.Name == "" {
+		// "GET items {id}", which also makes a readable file name
+		segments := strings.FieldsFunc(s.converter.ConvertPattern(req.URL, nil), func(r rune) bool { return r == '/' })
+		req.Meta.Name = strings.Join(append([]string{req.Method}, segments...), " ")
+	}
+	if req.Meta.Type == "" {
+		req.Meta.Type = "http"
+	}
+	if req.Meta.Seq == 0 {
+		req.Meta.Seq = 1
+	}
+
+	s.stubs.mu.Lock()
+	defer s.stubs.mu.Unlock()
+
+	if id == "" {
+		s.stubs.nextID++
+		id = "stub-" + strconv.Itoa(s.stubs.nextID)
+	}
+
+	filePath := ""
+	if persist {
+		var err error
+		if filePath, err = s.stubFilePath(req); err != nil {
+			return nil, false, err
+		}
+		if err := s.stubs.checkOverwrite(id, filePath); err != nil {
+			return nil, false, err
+		}
+	}
+	req.FilePath = filePath
+	if req.FilePath == "" {
+		req.FilePath = "stub " + id
+	}
+
+	// Validate exactly like a .bru file, so a persisted stub loads the same way
+	normalized, err := brunoformat.ReparseRequest(req)
+	if err != nil {
+		return nil, false, err
+	}
+	if normalized.Method == "" {
+		return nil, false, fmt.Errorf("unsupported method %q", req.Method)
+	}
+	normalized.FilePath = req.FilePath
+
+	previous := s.stubs.stubs
+	stubs := slices.Clone(previous)
+	stub := &Stub{ID: id, Request: normalized, Persisted: filePath}
+	i := s.stubs.index(id)
+	if i >= 0 {
+		stubs[i] = stub
+	} else {
+		stubs = append(stubs, stub)
+	}
+	if err := s.installStubs(stubs, s.stubs.envs); err != nil {
+		return nil, false, err
+	}
+
+	if persist {
+		if err := s.files.WriteFile(filePath, normalized); err != nil {
+			s.installStubs(previous, s.stubs.envs)
+			return nil, false, err
+		}
+		log.Printf("Persisted stub %s to %s", id, filePath)
+	}
+	stub, _ = s.stub(id)
+	return stub, i < 0, nil
+}
+
+// validateStub checks what would be written differently than given if the
+// stub were serialized, so mistakes are reported instead of reinterpreted.
+// Fixture files need a persisted stub to be resolved against, and are
+// confined to the collection directory like those of .bru files.
+func validateStub(req *brunoformat.BrunoRequest, persist bool) error {
+	for _, example := range req.Examples {
+		if example.Response.Body.File != "" && !persist {
+			return fmt.Errorf("example %q: body file requires a persisted stub", example.Name)
+		}
+		for _, rule := range example.Match {
+			if rule.Operator != "" && !slices.Contains(brunoformat.MatchOperators, rule.Operator) {
+				return fmt.Errorf("example %q: unknown match operator %q", example.Name, rule.Operator)
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteStub removes a stub; a file it was persisted to is kept.
+// It reports false if no stub has the ID.
+func (s *MockService) DeleteStub(id string) bool {
+	s.stubs.mu.Lock()
+	defer s.stubs.mu.Unlock()
+
+	i := s.stubs.index(id)
+	if i < 0 {
+		return false
+	}
+	stubs := slices.Delete(slices.Clone(s.stubs.stubs), i, i+1)
+	if err := s.installStubs(stubs, s.stubs.envs); err != nil {
+		// Removing routes from a router that built before cannot fail
+		log.Printf("Warning: failed to rebuild stubs: %v", err)
+	}
+	return true
+}
+
+// ResetStubs removes all stubs
+func (s *MockService) ResetStubs() {
+	s.stubs.mu.Lock()
+	defer s.stubs.mu.Unlock()
+	s.installStubs(nil, s.stubs.envs)
+}
+
+// index returns the position of the stub with the given ID, or -1; mu must be held
+func (st *StubState) index(id string) int {
+	return slices.IndexFunc(st.stubs, func(stub *Stub) bool { return stub.ID == id })
+}
+
+// checkOverwrite fails with ErrStubFileExists if a file exists at path that
+// the stub with the given ID did not persist; mu must be held
+func (st *StubState) checkOverwrite(id, path string) error {
+	if i := st.index(id); i >= 0 && st.stubs[i].Persisted == path {
+		return nil
+	}
+	_, err := os.Stat(path)
+	if err == nil {
+		return fmt.Errorf("%w: %s was not written by stub %s", ErrStubFileExists, path, id)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// stubFilePath returns where a stub is persisted, refusing paths outside the collection
+func (s *MockService) stubFilePath(req *brunoformat.BrunoRequest) (string, error) {
+	if s.files == nil {
+		return "", fmt.Errorf("persisting stubs is not available")
+	}
+	if req.FilePath == "" {
+		return s.files.GenerateFilePath(s.baseDir, req.URL, req.Meta.Name), nil
+	}
+
+	path := filepath.Join(s.baseDir, req.FilePath)
+	if !insideDir(s.baseDir, path) {
+		return "", fmt.Errorf("filePath %q is outside the collection", req.FilePath)
+	}
+	if filepath.Ext(path) != ".bru" {
+		return "", fmt.Errorf("filePath %q must end in .bru", req.FilePath)
+	}
+	return path, nil
+}
+
+// SetStubEnvironments rebuilds the stubs for the environments of new route
+// tables; the first environment is the default
+func (s *MockService) SetStubEnvironments(envs []Environment) {
+	s.stubs.mu.Lock()
+	defer s.stubs.mu.Unlock()
+	if err := s.installStubs(s.stubs.stubs, envs); err != nil {
+		log.Printf("Warning: failed to rebuild stubs for the new environment: %v", err)
+	}
+}
+
+// installStubs builds a router per environment for the stubs and makes them
+// active. On error the current stubs stay active. stubs.mu must be held.
+func (s *MockService) installStubs(stubs []*Stub, envs []Environment) (err error) {
+	if len(stubs) == 0 {
+		s.stubs.stubs, s.stubs.envs = nil, envs
+		s.stubs.routers.Store(nil)
+		return nil
+	}
+	if len(envs) == 0 {
+		envs = []Environment{{Vars: map[string]string{}}}
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("failed to build stub routes: %v", p)
+		}
+	}()
+
+	// Stubs are copied rather than updated, since readers may hold the old ones
+	installed := make([]*Stub, len(stubs))
+	routers := make(map[string]*hostRouter, len(envs))
+	for _, env := range envs {
+		hosts := make([]string, len(stubs))
+		for i, stub := range stubs {
+			hosts[i] = s.converter.Host(stub.Request.URL, env.Vars)
+		}
+
+		hr, err := buildHostRouter(hosts, s.fallbackHost(env.Hosts), func(_ string, indexes []int) (*chi.Mux, error) {
+			router := chi.NewRouter()
+			for _, i := range indexes {
+				stub := stubs[i]
+				path := s.converter.ConvertPattern(stub.Request.URL, env.Vars)
+				rt, err := s.newRoute(stub.Request, env.Vars)
+				if err != nil {
+					return nil, stubError(stub, env, envs, err)
+				}
+				rt.key = stub.Request.Method + " " + path
+
+				handler, _ := s.routeHandler(rt, path, nil)
+				if err := registerRoute(router, stub.Request.Method, path, handler); err != nil {
+					return nil, stubError(stub, env, envs, err)
+				}
+
+				// Stubs report their route in the default environment
+				if installed[i] == nil {
+					copied := *stub
+					copied.Route = rt.key
+					installed[i] = &copied
+				}
+			}
+			return router, nil
+		})
+		if err != nil {
+			return err
+		}
+		routers[env.Name] = hr
+	}
+
+	s.stubs.stubs, s.stubs.envs = installed, envs
+	s.stubs.routers.Store(&routers)
+	return nil
+}
+
+// stubError names the stub, and the environment if there are several, in an error
+func stubError(stub *Stub, env Environment, envs []Environment, err error) error {
+	if len(envs) > 1 {
+		return fmt.Errorf("stub %s in environment %q: %w", stub.ID, env.Name, err)
+	}
+	return fmt.Errorf("stub %s: %w", stub.ID, err)
+}
+
+// serveStubs answers requests that match a stub in the given environment and
+// passes all others on to the routes loaded from .bru files
+func (s *MockService) serveStubs(envName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if routers := s.stubs.routers.Load(); routers != nil {
+				if hr := (*routers)[envName]; hr != nil {
+					if router := hr.router(r); router.Match(chi.NewRouteContext(), r.Method, r.URL.Path) {
+						router.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package templating
+
+import (
+	"crypto/rand"
+	"fmt"
+	mathrand "math/rand/v2"
+	"strings"
+	"time"
+)
+
+// generator produces a fresh value for a Bruno dynamic variable
+type generator func() interface{}
+
+// generators maps dynamic variable names (without the leading "$") to their generators.
+// Names follow Bruno's built-in dynamic variables.
+var generators = map[string]generator{
+	"guid":               randomUUID,
+	"randomUUID":         randomUUID,
+	"timestamp":          func() interface{} { return time.Now().Unix() },
+	"isoTimestamp":       func() interface{} { return time.Now().UTC().Format(time.RFC3339) },
+	"randomInt":          func() interface{} { return mathrand.IntN(1001) },
+	"randomFloat":        func() interface{} { return float64(mathrand.IntN(100000)) / 100 },
+	"randomBoolean":      func() interface{} { return mathrand.IntN(2) == 1 },
+	"randomAlphaNumeric": func() interface{} { return randomString("abcdefghijklmnopqrstuvwxyz0123456789", 1) },
+	"randomHexColor":     func() interface{} { return "#" + randomString("0123456789abcdef", 6) },
+	"randomIP": func() interface{} {
+		return fmt.Sprintf("%d.%d.%d.%d", mathrand.IntN(256), mathrand.IntN(256), mathrand.IntN(256), mathrand.IntN(256))
+	},
+	"randomFirstName": func() interface{} { return pick(firstNames) },
+	"randomLastName":  func() interface{} { return pick(lastNames) },
+	"randomFullName":  func() interface{} { return pick(firstNames) + " " + pick(lastNames) },
+	"randomUserName": func() interface{} {
+		return strings.ToLower(pick(firstNames)) + fmt.Sprintf("%d", mathrand.IntN(100))
+	},
+	"randomEmail": func() interface{} {
+		return strings.ToLower(pick(firstNames)+"."+pick(lastNames)) + "@example.com"
+	},
+	"randomWord":      func() interface{} { return pick(words) },
+	"randomLoremWord": func() interface{} { return pick(words) },
+}
+
+var (
+	firstNames = []string{"Alice", "Bob", "Carol", "Dave", "Eve", "Frank", "Grace", "Heidi", "Ivan", "Judy"}
+	lastNames  = []string{"Smith", "Johnson", "Brown", "Taylor", "Miller", "Wilson", "Moore", "Clark", "Lewis", "Young"}
+	words      = []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel", "india", "juliet"}
+)
+
+// randomUUID returns a random version 4 UUID
+func randomUUID() interface{} {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// randomString returns n random characters from alphabet
+func randomString(alphabet string, n int) string {
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = alphabet[mathrand.IntN(len(alphabet))]
+	}
+	return string(out)
+}
+
+// pick returns a random element of list
+func pick(list []string) string {
+	return list[mathrand.IntN(len(list))]
+}
@@ -0,0 +1,250 @@
+package templating
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/anu-mdl/linker-bruno/internal/shared/jsonpath"
+)
+
+// exprRe matches the inside of a {{...}} placeholder. Anything else between
+// double braces (e.g. nested JSON objects) is left untouched.
+var exprRe = regexp.MustCompile(`^\s*(\$?[A-Za-z_][\w.\-\[\]]*)\s*$`)
+
+// Context supplies the values a template can reference when rendering
+type Context struct {
+	Request *http.Request
+	Params  map[string]string // chi path parameters
+	Env     map[string]string // Bruno environment variables
+	// Body returns the raw request body and, if it is JSON, its decoded value
+	Body func() (raw []byte, decoded interface{}, isJSON bool)
+}
+
+// Template is a response body or header value compiled into literal and expression parts
+type Template struct {
+	parts []part
+}
+
+// part is either literal text or a placeholder expression
+type part struct {
+	literal  string
+	expr     expression
+	inString bool // placeholder sits inside a JSON string literal
+	bare     bool // placeholder is a JSON value of its own, outside any string literal
+}
+
+// expression is a parsed placeholder
+type expression struct {
+	raw   string   // original placeholder text, rendered back when unresolved
+	scope string   // "request", "process", "dynamic" or "var"
+	path  []string // remaining path segments
+	gen   generator
+}
+
+// Compile parses text into a Template. When jsonMode is set, placeholders
+// inside JSON strings have their values escaped and placeholders outside
+// strings render JSON values (numbers, objects, null) as-is.
+func Compile(text string, jsonMode bool) (*Template, error) {
+	t := &Template{}
+	inString := false
+	start := 0
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+
+		if jsonMode && c == '"' && !escaped(text, i) {
+			inString = !inString
+			continue
+		}
+
+		if c != '{' || !strings.HasPrefix(text[i:], "{{") {
+			continue
+		}
+
+		end := strings.Index(text[i+2:], "}}")
+		if end < 0 {
+			break
+		}
+		inner := text[i+2 : i+2+end]
+		match := exprRe.FindStringSubmatch(inner)
+		if match == nil {
+			continue
+		}
+
+		expr, err := parseExpression(match[1], text[i:i+4+end])
+		if err != nil {
+			return nil, err
+		}
+
+		if start < i {
+			t.parts = append(t.parts, part{literal: text[start:i]})
+		}
+		t.parts = append(t.parts, part{expr: expr, inString: jsonMode && inString, bare: jsonMode && !inString})
+
+		i += 3 + end
+		start = i + 1
+	}
+
+	if start < len(text) {
+		t.parts = append(t.parts, part{literal: text[start:]})
+	}
+
+	return t, nil
+}
+
+// IsStatic reports whether the template has no placeholders
+func (t *Template) IsStatic() bool {
+	for _, p := range t.parts {
+		if p.expr.scope != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Render evaluates the template against a context
+func (t *Template) Render(ctx *Context) string {
+	var sb strings.Builder
+	for _, p := range t.parts {
+		if p.expr.scope == "" {
+			sb.WriteString(p.literal)
+			continue
+		}
+		sb.WriteString(p.render(ctx))
+	}
+	return sb.String()
+}
+
+// parseExpression classifies a placeholder expression
+func parseExpression(expr, raw string) (expression, error) {
+	if strings.HasPrefix(expr, "$") {
+		gen, ok := generators[expr[1:]]
+		if !ok {
+			return expression{}, fmt.Errorf("unknown dynamic variable %s", raw)
+		}
+		return expression{raw: raw, scope: "dynamic", gen: gen}, nil
+	}
+
+	segments := jsonpath.Split(expr)
+	switch {
+	case segments[0] == "request" && len(segments) > 1:
+		return expression{raw: raw, scope: "request", path: segments[1:]}, nil
+	case segments[0] == "process" && len(segments) == 3 && segments[1] == "env":
+		return expression{raw: raw, scope: "process", path: segments[2:]}, nil
+	}
+
+	// Plain names refer to path parameters or environment variables
+	return expression{raw: raw, scope: "var", path: []string{expr}}, nil
+}
+
+// render evaluates a single placeholder. A missing value renders as null
+// where it stands for a JSON value and as an empty string anywhere else.
+func (p part) render(ctx *Context) string {
+	value, ok := p.expr.evaluate(ctx)
+	if !ok {
+		if p.expr.scope == "var" {
+			// Unknown variables stay as written
+			return p.expr.raw
+		}
+		value = nil
+	}
+	if value == nil && !p.bare {
+		return ""
+	}
+
+	if p.inString {
+		encoded, _ := json.Marshal(jsonpath.Stringify(value))
+		return string(encoded[1 : len(encoded)-1])
+	}
+	if str, isString := value.(string); isString {
+		return str
+	}
+	return jsonpath.Stringify(value)
+}
+
+// evaluate resolves an expression to a value
+func (e expression) evaluate(ctx *Context) (interface{}, bool) {
+	switch e.scope {
+	case "dynamic":
+		return e.gen(), true
+	case "process":
+		return os.LookupEnv(e.path[0])
+	case "var":
+		if value, ok := ctx.Params[e.path[0]]; ok {
+			return value, true
+		}
+		value, ok := ctx.Env[e.path[0]]
+		return value, ok
+	case "request":
+		return evaluateRequest(ctx, e.path)
+	}
+	return nil, false
+}
+
+// evaluateRequest resolves request.* expressions
+func evaluateRequest(ctx *Context, path []string) (interface{}, bool) {
+	r := ctx.Request
+	if r == nil {
+		return nil, false
+	}
+
+	switch path[0] {
+	case "method":
+		return r.Method, true
+	case "path":
+		return r.URL.Path, true
+	case "url":
+		return r.URL.String(), true
+	case "host":
+		return r.Host, true
+	case "query", "headers", "params":
+		if len(path) != 2 {
+			return nil, false
+		}
+		var values []string
+		switch path[0] {
+		case "query":
+			values = r.URL.Query()[path[1]]
+		case "headers":
+			values = r.Header.Values(path[1])
+		default:
+			if value, ok := ctx.Params[path[1]]; ok {
+				values = []string{value}
+			}
+		}
+		if len(values) == 0 {
+			return nil, false
+		}
+		return values[0], true
+	case "body":
+		if ctx.Body == nil {
+			return nil, false
+		}
+		raw, decoded, isJSON := ctx.Body()
+		if len(path) == 1 {
+			if isJSON {
+				return decoded, true
+			}
+			return string(raw), true
+		}
+		if !isJSON {
+			return nil, false
+		}
+		return jsonpath.Lookup(decoded, strings.Join(path[1:], "."))
+	}
+
+	return nil, false
+}
+
+// escaped reports whether the byte at index i is preceded by an odd number of backslashes
+func escaped(text string, i int) bool {
+	count := 0
+	for j := i - 1; j >= 0 && text[j] == '\\'; j-- {
+		count++
+	}
+	return count%2 == 1
+}
@@ -0,0 +1,118 @@
+package templating
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	t.Setenv("TEMPLATE_TEST_REGION", "eu-west-1")
+
+	tests := []struct {
+		name     string
+		text     string
+		jsonMode bool
+		want     string
+	}{
+		{name: "literal", text: "plain text", want: "plain text"},
+		{name: "method and path", text: "{{request.method}} {{request.path}}", want: "POST /users/42"},
+		{name: "query", text: "page {{request.query.page}}", want: "page 2"},
+		{name: "header", text: "{{request.headers.X-Tenant}}", want: "acme"},
+		{name: "path parameter", text: "user {{id}} {{request.params.id}}", want: "user 42 42"},
+		{name: "environment variable", text: "{{baseUrl}}/users", want: "http://api.test/users"},
+		{name: "process environment", text: "{{process.env.TEMPLATE_TEST_REGION}}", want: "eu-west-1"},
+		{name: "unknown variable stays as written", text: "{{nope}}", want: "{{nope}}"},
+		{name: "missing value in text", text: "[{{request.query.missing}}]", want: "[]"},
+		{name: "not a placeholder", text: "{{ not an expression }}", want: "{{ not an expression }}"},
+		{name: "body field", text: "{{request.body.user.name}}", want: `Ada "the first"`},
+
+		{name: "json number", jsonMode: true, text: `{"page": {{request.query.page}}}`, want: `{"page": 2}`},
+		{name: "json object", jsonMode: true, text: `{"user": {{request.body.user}}}`, want: `{"user": {"name":"Ada \"the first\"","tags":["a","b"]}}`},
+		{name: "json string escaped", jsonMode: true, text: `{"name": "{{request.body.user.name}}"}`, want: `{"name": "Ada \"the first\""}`},
+		{name: "json missing bare", jsonMode: true, text: `{"page": {{request.query.missing}}}`, want: `{"page": null}`},
+		{name: "json missing in string", jsonMode: true, text: `{"page": "p{{request.query.missing}}"}`, want: `{"page": "p"}`},
+		{name: "json escaped quote", jsonMode: true, text: `{"a": "\"{{request.query.page}}", "b": {{request.query.page}}}`, want: `{"a": "\"2", "b": 2}`},
+	}
+
+	body := `{"user": {"name": "Ada \"the first\"", "tags": ["a", "b"]}}`
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Compile(tt.text, tt.jsonMode)
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			if got := tmpl.Render(testContext(body)); got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "unknown dynamic variable", text: "id: {{$nope}}", want: "unknown dynamic variable {{$nope}}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.text, false)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Compile() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDynamicVariables(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		pattern string
+	}{
+		{name: "uuid", text: "{{$randomUUID}}", pattern: `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{name: "timestamp", text: "{{$timestamp}}", pattern: `^\d{10}$`},
+		{name: "int", text: "{{$randomInt}}", pattern: `^\d{1,4}$`},
+		{name: "boolean", text: "{{$randomBoolean}}", pattern: `^(true|false)$`},
+		{name: "hex color", text: "{{$randomHexColor}}", pattern: `^#[0-9a-f]{6}$`},
+		{name: "email", text: "{{$randomEmail}}", pattern: `^[a-z]+\.[a-z]+@example\.com$`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Compile(tt.text, false)
+			if err != nil {
+				t.Fatalf("Compile: %v", err)
+			}
+			if tmpl.IsStatic() {
+				t.Errorf("IsStatic() = true for a dynamic variable")
+			}
+			if got := tmpl.Render(&Context{}); !regexp.MustCompile(tt.pattern).MatchString(got) {
+				t.Errorf("Render() = %q, want a match for %s", got, tt.pattern)
+			}
+		})
+	}
+}
+
+// testContext returns a context for POST /users/42?page=2 with the given JSON body
+func testContext(body string) *Context {
+	r := httptest.NewRequest("POST", "/users/42?page=2", strings.NewReader(body))
+	r.Header.Set("X-Tenant", "acme")
+
+	var decoded interface{}
+	isJSON := json.Unmarshal([]byte(body), &decoded) == nil
+	return &Context{
+		Request: r,
+		Params:  map[string]string{"id": "42"},
+		Env:     map[string]string{"baseUrl": "http://api.test"},
+		Body: func() ([]byte, interface{}, bool) {
+			return []byte(body), decoded, isJSON
+		},
+	}
+}
@@ -0,0 +1,65 @@
+package recorder
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+
+	"github.com/anu-mdl/linker-bruno/internal/modules/recorder/service"
+	"github.com/anu-mdl/linker-bruno/internal/modules/webui/repository"
+	"github.com/anu-mdl/linker-bruno/internal/shared/brunoformat"
+	"github.com/go-chi/chi/v5"
+)
+
+// Options configures the recorder
+type Options struct {
+	// Upstream is the URL of the real API to record
+	Upstream string
+	// AllowHeaders, DenyHeaders and RedactFields control what ends up in the
+	// recorded files; see service.Options
+	AllowHeaders []string
+	DenyHeaders  []string
+	RedactFields []string
+}
+
+// Module represents the record mode module with all its dependencies
+type Module struct {
+	baseDir  string
+	recorder *service.Recorder
+}
+
+// NewModule creates and initializes a new recorder module
+func NewModule(baseDir string, opts Options) (*Module, error) {
+	upstream, err := url.Parse(opts.Upstream)
+	if err != nil || upstream.Scheme == "" || upstream.Host == "" {
+		return nil, fmt.Errorf("invalid record upstream %q: expected an absolute URL like https://api.example.com", opts.Upstream)
+	}
+
+	// Initialize dependencies
+	serializer := brunoformat.NewSerializer()
+
+	// Create repository
+	fileRepo := repository.NewFileRepository(serializer)
+
+	// Create service
+	recorder := service.NewRecorder(baseDir, upstream, fileRepo, service.Options{
+		AllowHeaders: opts.AllowHeaders,
+		DenyHeaders:  opts.DenyHeaders,
+		RedactFields: opts.RedactFields,
+	})
+
+	log.Printf("Recording requests to %s into %s", upstream, baseDir)
+
+	return &Module{
+		baseDir:  baseDir,
+		recorder: recorder,
+	}, nil
+}
+
+// RegisterRoutes installs the recorder as the fallback handler of the
+// provided router, so routes defined directly on the router (e.g. the web UI)
+// take precedence
+func (m *Module) RegisterRoutes(router chi.Router) {
+	router.NotFound(m.recorder.ServeHTTP)
+	router.MethodNotAllowed(m.recorder.ServeHTTP)
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	uuidRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hexRe  = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+)
+
+// TemplatePath replaces path segments that look like IDs with {param}s named
+// after the preceding segment: /users/42/posts/7 becomes
+// /users/{userId}/posts/{postId}. Names depend only on the preceding segment,
+// so recordings of the same resource never disagree on parameter names.
+func TemplatePath(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		if !isIDLike(segment) {
+			continue
+		}
+		name := "id"
+		if i > 0 && !strings.HasPrefix(segments[i-1], "{") {
+			name = paramName(segments[i-1])
+		}
+		segments[i] = "{" + name + "}"
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// isIDLike reports whether a path segment looks like an identifier rather than
+// a fixed part of the API: numbers, UUIDs, long hex strings and tokens mixing
+// letters and digits
+func isIDLike(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	if uuidRe.MatchString(segment) || hexRe.MatchString(segment) {
+		return true
+	}
+
+	digits, letters := 0, 0
+	for _, r := range segment {
+		switch {
+		case unicode.IsDigit(r):
+			digits++
+		case unicode.IsLetter(r):
+			letters++
+		case r != '-' && r != '_':
+			return false
+		}
+	}
+	if letters == 0 {
+		return digits > 0
+	}
+	// Versions like v1 or v2beta are not IDs
+	return digits > 0 && len(segment) >= 8
+}
+
+// paramName derives a parameter name from a collection segment: users -> userId
+func paramName(collection string) string {
+	words := strings.FieldsFunc(collection, func(r rune) bool { return r == '-' || r == '_' || r == '.' })
+	if len(words) == 0 {
+		return "id"
+	}
+	for i := 1; i < len(words); i++ {
+		words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+	}
+	name := strings.Join(words, "")
+	switch {
+	case strings.HasSuffix(name, "ies"):
+		name = strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "ses"):
+		name = strings.TrimSuffix(name, "es")
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss"):
+		name = strings.TrimSuffix(name, "s")
+	}
+	return name + "Id"
+}
+
+// requestName names a recorded request after its method and path,
+// e.g. "GET users userId"
+func requestName(method, path string) string {
+	words := []string{method}
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		if segment = strings.Trim(segment, "{}"); segment != "" {
+			words = append(words, segment)
+		}
+	}
+	return strings.Join(words, " ")
+}
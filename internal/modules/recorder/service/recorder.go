@@ -0,0 +1,378 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/anu-mdl/linker-bruno/internal/modules/webui/repository"
+	"github.com/anu-mdl/linker-bruno/internal/shared/brunoformat"
+	"github.com/anu-mdl/linker-bruno/internal/shared/response"
+)
+
+// maxRecordedBody caps how much of a request or response body is recorded
+const maxRecordedBody = 10 << 20
+
+// Options configures what is recorded
+type Options struct {
+	// AllowHeaders, if set, records only these headers
+	AllowHeaders []string
+	// DenyHeaders are never recorded; they are added to DefaultDenyHeaders
+	DenyHeaders []string
+	// RedactFields are JSON field names whose values are replaced by RedactedValue;
+	// they are added to DefaultRedactFields
+	RedactFields []string
+}
+
+// DefaultDenyHeaders are never recorded because they carry credentials or
+// describe the transfer rather than the response
+var DefaultDenyHeaders = []string{
+	"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key",
+	"Content-Length", "Transfer-Encoding", "Connection", "Keep-Alive", "Date",
+	"Accept-Encoding", "Content-Encoding", "X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto",
+}
+
+// DefaultRedactFields are JSON fields that are always redacted
+var DefaultRedactFields = []string{
+	"password", "token", "accessToken", "access_token", "refreshToken", "refresh_token",
+	"secret", "clientSecret", "client_secret", "apiKey", "api_key",
+}
+
+// RedactedValue replaces the values of redacted JSON fields
+const RedactedValue = "[REDACTED]"
+
+// Recorder forwards requests to an upstream and writes every exchange into
+// the collection as a .bru example
+type Recorder struct {
+	baseDir string
+	repo    *repository.FileRepository
+	proxy   *httputil.ReverseProxy
+	allow   map[string]bool
+	deny    map[string]bool
+	redact  map[string]bool
+
+	// mu serializes read-modify-write cycles on .bru files
+	mu sync.Mutex
+}
+
+// NewRecorder creates a Recorder that writes into baseDir
+func NewRecorder(baseDir string, upstream *url.URL, repo *repository.FileRepository, opts Options) *Recorder {
+	rec := &Recorder{
+		baseDir: baseDir,
+		repo:    repo,
+		allow:   fieldSet(opts.AllowHeaders, http.CanonicalHeaderKey),
+		deny:    fieldSet(slices.Concat(DefaultDenyHeaders, opts.DenyHeaders), http.CanonicalHeaderKey),
+		redact:  fieldSet(slices.Concat(DefaultRedactFields, opts.RedactFields), strings.ToLower),
+	}
+
+	rec.proxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(upstream)
+			pr.SetXForwarded()
+			// Ask for an uncompressed body so it can be recorded as is
+			pr.Out.Header.Del("Accept-Encoding")
+		},
+		ModifyResponse: rec.record,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("Upstream error for %s %s: %v", r.Method, r.URL.Path, err)
+			response.WriteError(w, http.StatusBadGateway, "BAD_GATEWAY", "upstream request failed: "+err.Error())
+		},
+	}
+	return rec
+}
+
+// fieldSet builds a lookup set of normalized names
+func fieldSet(names []string, normalize func(string) string) map[string]bool {
+	if len(names) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			set[normalize(name)] = true
+		}
+	}
+	return set
+}
+
+// exchangeKey carries the client request and its captured body through the proxy
+type exchangeKey struct{}
+
+// exchange is the client side of a proxied request
+type exchange struct {
+	request *http.Request
+	body    *capture // nil when the request has no body
+}
+
+// capture passes a body through unchanged while keeping a copy of up to
+// maxRecordedBody bytes for the recording
+type capture struct {
+	io.ReadCloser
+	buf      bytes.Buffer
+	overflow bool // the body was larger than maxRecordedBody; buf is empty
+	complete bool // the body was read to the end
+	onClose  func(*capture)
+}
+
+func (c *capture) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 && !c.overflow {
+		if c.buf.Len()+n > maxRecordedBody {
+			c.overflow = true
+			c.buf = bytes.Buffer{}
+		} else {
+			c.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF {
+		c.complete = true
+	}
+	return n, err
+}
+
+func (c *capture) Close() error {
+	err := c.ReadCloser.Close()
+	if c.onClose != nil {
+		c.onClose(c)
+		c.onClose = nil
+	}
+	return err
+}
+
+// ServeHTTP forwards the request to the upstream and records the exchange
+func (rec *Recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ex := &exchange{request: r}
+	if r.Body != nil && r.Body != http.NoBody {
+		ex.body = &capture{ReadCloser: r.Body}
+		r.Body = ex.body
+	}
+
+	ctx := context.WithValue(r.Context(), exchangeKey{}, ex)
+	rec.proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// record arranges for the exchange to be saved once the upstream response
+// has been passed on to the client in full. Streaming responses and bodies
+// over maxRecordedBody are passed on but not recorded.
+func (rec *Recorder) record(resp *http.Response) error {
+	ex, ok := resp.Request.Context().Value(exchangeKey{}).(*exchange)
+	if !ok {
+		return nil
+	}
+	r := ex.request
+
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "text/event-stream" {
+		log.Printf("Not recording %s %s: streaming responses are only passed through", r.Method, r.URL.Path)
+		return nil
+	}
+
+	resp.Body = &capture{ReadCloser: resp.Body, onClose: func(c *capture) {
+		switch {
+		case !c.complete:
+			log.Printf("Not recording %s %s: the response was not read to the end", r.Method, r.URL.Path)
+			return
+		case c.overflow:
+			log.Printf("Not recording %s %s: the response body exceeds %d bytes", r.Method, r.URL.Path, maxRecordedBody)
+			return
+		}
+
+		var reqBody []byte
+		if ex.body != nil {
+			if ex.body.overflow {
+				log.Printf("Recording %s %s without its request body, which exceeds %d bytes", r.Method, r.URL.Path, maxRecordedBody)
+			} else {
+				reqBody = ex.body.buf.Bytes()
+			}
+		}
+		if err := rec.save(r, reqBody, resp, c.buf.Bytes()); err != nil {
+			log.Printf("Warning: failed to record %s %s: %v", r.Method, r.URL.Path, err)
+		}
+	}}
+	return nil
+}
+
+// save writes the exchange as a new .bru file, or as a new example on the
+// file previously recorded for the same method and path
+func (rec *Recorder) save(r *http.Request, reqBody []byte, resp *http.Response, respBody []byte) error {
+	path := TemplatePath(r.URL.Path)
+	brunoURL := "{{baseUrl}}" + path
+	name := requestName(r.Method, path)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	filePath := rec.repo.GenerateFilePath(rec.baseDir, brunoURL, name)
+
+	req, err := rec.repo.ReadFile(filePath)
+	if err != nil {
+		if _, statErr := os.Stat(filePath); statErr == nil {
+			return err // exists but does not parse; leave it alone
+		}
+		req = &brunoformat.BrunoRequest{
+			FilePath:    filePath,
+			Meta:        brunoformat.MetaBlock{Name: name, Type: "http", Seq: 1},
+			Method:      r.Method,
+			URL:         brunoURL,
+			Headers:     rec.headers(r.Header),
+			QueryParams: make(map[string]string),
+			Body:        rec.requestBody(reqBody),
+		}
+		for key, values := range r.URL.Query() {
+			req.QueryParams[key] = values[0]
+			if rec.redact[strings.ToLower(key)] {
+				req.QueryParams[key] = RedactedValue
+			}
+		}
+	}
+
+	example := rec.example(r, len(reqBody) > 0, resp, respBody)
+	for _, existing := range req.Examples {
+		if existing.Response.Status.Code == example.Response.Status.Code &&
+			existing.Response.Body.Content == example.Response.Body.Content {
+			return nil // already recorded
+		}
+	}
+	example.Name = uniqueExampleName(req, example.Name)
+	req.Examples = append(req.Examples, example)
+
+	if err := rec.repo.WriteFile(filePath, req); err != nil {
+		return err
+	}
+
+	log.Printf("Recorded %s %s -> %s (example %q)", r.Method, r.URL.Path, filePath, example.Name)
+	return nil
+}
+
+// example builds an example block from an upstream response
+func (rec *Recorder) example(r *http.Request, hasBody bool, resp *http.Response, body []byte) brunoformat.ExampleBlock {
+	status := fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	bodyType, content := rec.responseBody(resp.Header.Get("Content-Type"), body)
+
+	mode := "none"
+	if hasBody {
+		mode = "json"
+	}
+
+	// Secrets passed as query parameters are redacted like JSON fields
+	requestURI := r.URL.EscapedPath()
+	if query := RedactQuery(r.URL.RawQuery, rec.redact); query != "" {
+		requestURI += "?" + query
+	}
+
+	return brunoformat.ExampleBlock{
+		Name: strings.TrimSpace(status),
+		Request: brunoformat.ExampleRequest{
+			URL:    requestURI,
+			Method: r.Method,
+			Mode:   mode,
+		},
+		Response: brunoformat.ExampleResponse{
+			Headers: rec.headers(resp.Header),
+			Status: brunoformat.ExampleStatus{
+				Code: resp.StatusCode,
+				Text: http.StatusText(resp.StatusCode),
+			},
+			Body: brunoformat.ExampleBody{
+				Type:    bodyType,
+				Content: content,
+			},
+		},
+	}
+}
+
+// headers returns the recordable headers, honouring the allow and deny lists
+func (rec *Recorder) headers(h http.Header) map[string]string {
+	headers := make(map[string]string)
+	for key, values := range h {
+		key = http.CanonicalHeaderKey(key)
+		if rec.deny[key] || (rec.allow != nil && !rec.allow[key]) {
+			continue
+		}
+		headers[strings.ToLower(key)] = strings.Join(values, ", ")
+	}
+	return headers
+}
+
+// requestBody returns the request body as recorded in the body:json block
+func (rec *Recorder) requestBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if text, ok := rec.formatJSON(body); ok {
+		return text
+	}
+	return string(body)
+}
+
+// responseBody picks the example body type for a Content-Type and formats the body
+func (rec *Recorder) responseBody(contentType string, body []byte) (string, string) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch {
+	case len(body) == 0:
+		return brunoformat.BodyJSON, ""
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		if text, ok := rec.formatJSON(body); ok {
+			return brunoformat.BodyJSON, text
+		}
+		return brunoformat.BodyText, string(body)
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return brunoformat.BodyXML, string(body)
+	case mediaType == "text/html":
+		return brunoformat.BodyHTML, string(body)
+	case strings.HasPrefix(mediaType, "text/"):
+		return brunoformat.BodyText, string(body)
+	case mediaType == "":
+		// Undeclared bodies are sniffed so JSON still gets redacted
+		if text, ok := rec.formatJSON(body); ok {
+			return brunoformat.BodyJSON, text
+		}
+		if utf8.Valid(body) {
+			return brunoformat.BodyText, string(body)
+		}
+		return brunoformat.BodyBase64, base64.StdEncoding.EncodeToString(body)
+	default:
+		return brunoformat.BodyBase64, base64.StdEncoding.EncodeToString(body)
+	}
+}
+
+// formatJSON redacts and indents a JSON document
+func (rec *Recorder) formatJSON(body []byte) (string, bool) {
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return "", false
+	}
+	data = Redact(data, rec.redact)
+
+	out, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return "", false
+	}
+	return string(out), true
+}
+
+// uniqueExampleName suffixes name with a counter if the request already has an example with it
+func uniqueExampleName(req *brunoformat.BrunoRequest, name string) string {
+	if req.FindExample(name) == nil {
+		return name
+	}
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)", name, i)
+		if req.FindExample(candidate) == nil {
+			return candidate
+		}
+	}
+}
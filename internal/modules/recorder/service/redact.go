@@ -0,0 +1,42 @@
+package service
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Redact replaces the values of JSON object fields whose lowercased names are
+// in fields, at any depth. data is a decoded JSON value and is modified in place.
+func Redact(data interface{}, fields map[string]bool) interface{} {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if fields[strings.ToLower(key)] {
+				v[key] = RedactedValue
+				continue
+			}
+			v[key] = Redact(value, fields)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = Redact(item, fields)
+		}
+	}
+	return data
+}
+
+// RedactQuery replaces the values of query parameters whose lowercased names
+// are in fields, keeping the order and encoding of the other parameters
+func RedactQuery(rawQuery string, fields map[string]bool) string {
+	if rawQuery == "" {
+		return ""
+	}
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(key); err == nil && fields[strings.ToLower(name)] {
+			params[i] = key + "=" + RedactedValue
+		}
+	}
+	return strings.Join(params, "&")
+}
@@ -1,13 +1,16 @@
 package delivery
 
 import (
+	"errors"
 	"html/template"
 	"log"
 	"net/http"
+	"slices"
 	"strconv"
 
 	"github.com/anu-mdl/linker-bruno/internal/modules/webui/dto"
 	"github.com/anu-mdl/linker-bruno/internal/modules/webui/service"
+	"github.com/anu-mdl/linker-bruno/internal/shared/brunoformat"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -42,7 +45,7 @@ func (h *APIHandler) HandleListRequests(w http.ResponseWriter, r *http.Request)
 	tree, err := h.service.BuildRequestTree(h.baseDir)
 	if err != nil {
 		log.Printf("Error building tree: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -64,6 +67,11 @@ func (h *APIHandler) HandleGetRequest(w http.ResponseWriter, r *http.Request) {
 	req, err := h.service.GetRequestByID(id)
 	if err != nil {
 		log.Printf("Error loading request: %v", err)
+		var parseErr *brunoformat.ParseError
+		if errors.As(err, &parseErr) {
+			http.Error(w, parseErr.Error(), http.StatusUnprocessableEntity)
+			return
+		}
 		http.Error(w, "Request not found", http.StatusNotFound)
 		return
 	}
@@ -96,10 +104,15 @@ func (h *APIHandler) HandleCreateRequest(w http.ResponseWriter, r *http.Request)
 		Headers:     make(map[string]string),
 		QueryParams: make(map[string]string),
 		Body:        r.FormValue("request_body"),
-		ResponseHeaders: map[string]string{
-			"content-type": "application/json",
-		},
-		ResponseBody: r.FormValue("response_body"),
+		ResponseHeaders:  make(map[string]string),
+		ResponseBody:     r.FormValue("response_body"),
+		ResponseBodyType: r.FormValue("response_body_type"),
+	}
+
+	// Validate the body type; the mock server would reject the file otherwise
+	if input.ResponseBodyType != "" && !slices.Contains(brunoformat.BodyTypes, input.ResponseBodyType) {
+		http.Error(w, "Invalid response body type", http.StatusBadRequest)
+		return
 	}
 
 	// Set default status if not provided
@@ -143,6 +156,8 @@ func (h *APIHandler) HandleUpdateRequest(w http.ResponseWriter, r *http.Request)
 		Description: r.FormValue("example_description"),
 		Body:        r.FormValue("request_body"),
 		ResponseBody: r.FormValue("response_body"),
+		ResponseBodyType: r.FormValue("response_body_type"),
+		ResponseBodyFile: r.FormValue("response_body_file"),
 	}
 
 	// Parse request headers from form arrays
@@ -179,6 +194,12 @@ func (h *APIHandler) HandleUpdateRequest(w http.ResponseWriter, r *http.Request)
 		input.ResponseHeaders = existingReq.ResponseHeaders
 	}
 
+	// Validate the body type; the mock server would reject the file otherwise
+	if input.ResponseBodyType != "" && !slices.Contains(brunoformat.BodyTypes, input.ResponseBodyType) {
+		http.Error(w, "Invalid response body type", http.StatusBadRequest)
+		return
+	}
+
 	// Parse status code and text
 	statusCode := existingReq.ResponseStatus.Code
 	statusText := existingReq.ResponseStatus.Text
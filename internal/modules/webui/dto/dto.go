@@ -26,6 +26,8 @@ type CreateRequestInput struct {
 	} `json:"responseStatus"`
 	ResponseHeaders map[string]string `json:"responseHeaders,omitempty"`
 	ResponseBody    string            `json:"responseBody,omitempty"`
+	ResponseBodyType string           `json:"responseBodyType,omitempty"` // json (default), text, xml, html or base64
+	ResponseBodyFile string           `json:"responseBodyFile,omitempty"` // fixture relative to the .bru file
 }
 
 // UpdateRequestInput represents input for updating an existing request
@@ -43,6 +45,8 @@ type UpdateRequestInput struct {
 	} `json:"responseStatus"`
 	ResponseHeaders map[string]string `json:"responseHeaders,omitempty"`
 	ResponseBody    string            `json:"responseBody,omitempty"`
+	ResponseBodyType string           `json:"responseBodyType,omitempty"` // json (default), text, xml, html or base64
+	ResponseBodyFile string           `json:"responseBodyFile,omitempty"` // fixture relative to the .bru file
 }
 
 // RequestResponse represents a request returned to the client
@@ -61,6 +65,9 @@ type RequestResponse struct {
 	} `json:"responseStatus"`
 	ResponseHeaders map[string]string `json:"responseHeaders,omitempty"`
 	ResponseBody    string            `json:"responseBody,omitempty"`
+	ResponseBodyType string           `json:"responseBodyType,omitempty"` // json (default), text, xml, html or base64
+	ResponseBodyFile string           `json:"responseBodyFile,omitempty"` // fixture relative to the .bru file
+	ExampleNames    []string          `json:"exampleNames,omitempty"` // all examples; the editor shows the first
 }
 
 // RequestListItem represents a request in the list/tree view
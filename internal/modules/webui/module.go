@@ -12,6 +12,9 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
+// APIPrefix is the path prefix of the web UI API, which edits the collection
+const APIPrefix = "/api/requests"
+
 // Module represents the web UI module with all its dependencies
 type Module struct {
 	baseDir    string
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -68,6 +69,7 @@ func (r *FileRepository) DeleteFile(filePath string) error {
 // ScanDirectory recursively scans a directory for all .bru files
 func (r *FileRepository) ScanDirectory(baseDir string) ([]*brunoformat.BrunoRequest, error) {
 	var requests []*brunoformat.BrunoRequest
+	var parseErrs []error
 
 	err := filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -97,8 +99,9 @@ func (r *FileRepository) ScanDirectory(baseDir string) ([]*brunoformat.BrunoRequ
 		// Parse the .bru file
 		req, err := brunoformat.ParseBrunoFile(path)
 		if err != nil {
-			log.Printf("Warning: failed to parse %s: %v", path, err)
-			return nil // Continue walking
+			// Collect the error and keep walking so all broken files are reported at once
+			parseErrs = append(parseErrs, err)
+			return nil
 		}
 
 		requests = append(requests, req)
@@ -109,6 +112,10 @@ func (r *FileRepository) ScanDirectory(baseDir string) ([]*brunoformat.BrunoRequ
 		return nil, fmt.Errorf("failed to walk directory %s: %w", baseDir, err)
 	}
 
+	if len(parseErrs) > 0 {
+		return nil, fmt.Errorf("failed to parse collection: %w", errors.Join(parseErrs...))
+	}
+
 	return requests, nil
 }
 
@@ -36,21 +36,27 @@ func (s *RequestService) GetRequestByID(id string) (*dto.RequestResponse, error)
 		return nil, fmt.Errorf("failed to read request: %w", err)
 	}
 
+	// The editor works on the default (first) example
+	example := req.DefaultExample()
+
 	// Convert to response DTO
 	response := &dto.RequestResponse{
 		ID:          id,
 		Name:        req.Meta.Name,
 		Method:      req.Method,
 		URL:         req.URL,
-		Description: req.Example.Description,
+		Description: example.Description,
 		Headers:     req.Headers,
 		QueryParams: req.QueryParams,
 		Body:        req.Body,
-		ResponseHeaders: req.Example.Response.Headers,
-		ResponseBody:    req.Example.Response.Body.Content,
+		ResponseHeaders: example.Response.Headers,
+		ResponseBody:    example.Response.Body.Content,
+		ResponseBodyType: example.Response.Body.Type,
+		ResponseBodyFile: example.Response.Body.File,
+		ExampleNames:    req.ExampleNames(),
 	}
-	response.ResponseStatus.Code = req.Example.Response.Status.Code
-	response.ResponseStatus.Text = req.Example.Response.Status.Text
+	response.ResponseStatus.Code = example.Response.Status.Code
+	response.ResponseStatus.Text = example.Response.Status.Text
 
 	return response, nil
 }
@@ -69,7 +75,7 @@ func (s *RequestService) CreateRequest(baseDir string, input *dto.CreateRequestI
 		Headers:     input.Headers,
 		QueryParams: input.QueryParams,
 		Body:        input.Body,
-		Example: brunoformat.ExampleBlock{
+		Examples: []brunoformat.ExampleBlock{{
 			Name:        input.Name + " Example",
 			Description: input.Description,
 			Request: brunoformat.ExampleRequest{
@@ -84,11 +90,12 @@ func (s *RequestService) CreateRequest(baseDir string, input *dto.CreateRequestI
 					Text: input.ResponseStatus.Text,
 				},
 				Body: brunoformat.ExampleBody{
-					Type:    "json",
+					Type:    bodyType(input.ResponseBodyType),
 					Content: input.ResponseBody,
+					File:    input.ResponseBodyFile,
 				},
 			},
-		},
+		}},
 	}
 
 	// Initialize maps if nil
@@ -98,8 +105,8 @@ func (s *RequestService) CreateRequest(baseDir string, input *dto.CreateRequestI
 	if req.QueryParams == nil {
 		req.QueryParams = make(map[string]string)
 	}
-	if req.Example.Response.Headers == nil {
-		req.Example.Response.Headers = make(map[string]string)
+	if req.Examples[0].Response.Headers == nil {
+		req.Examples[0].Response.Headers = make(map[string]string)
 	}
 
 	// Generate file path
@@ -119,40 +126,43 @@ func (s *RequestService) UpdateRequest(id string, input *dto.UpdateRequestInput)
 	// Decode ID to file path
 	filePath := s.converter.DecodeID(id)
 
-	// Create updated BrunoRequest from input
-	req := &brunoformat.BrunoRequest{
-		FilePath: filePath,
-		Meta: brunoformat.MetaBlock{
-			Name: input.Name,
-			Type: "http",
-			Seq:  1,
-		},
-		Method:      input.Method,
-		URL:         input.URL,
-		Headers:     input.Headers,
-		QueryParams: input.QueryParams,
-		Body:        input.Body,
-		Example: brunoformat.ExampleBlock{
-			Name:        input.Name + " Example",
-			Description: input.Description,
-			Request: brunoformat.ExampleRequest{
-				URL:    input.URL,
-				Method: input.Method,
-				Mode:   "none",
-			},
-			Response: brunoformat.ExampleResponse{
-				Headers: input.ResponseHeaders,
-				Status: brunoformat.ExampleStatus{
-					Code: input.ResponseStatus.Code,
-					Text: input.ResponseStatus.Text,
-				},
-				Body: brunoformat.ExampleBody{
-					Type:    "json",
-					Content: input.ResponseBody,
-				},
-			},
-		},
+	// Load the existing file so additional examples survive the edit
+	existing, err := s.repo.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read request: %w", err)
+	}
+
+	// Start from the existing request so the examples, mock settings and
+	// example fields the editor does not expose survive the edit
+	req := *existing
+	req.FilePath = filePath
+	req.Meta.Name = input.Name
+	req.Method = input.Method
+	req.URL = input.URL
+	req.Headers = input.Headers
+	req.QueryParams = input.QueryParams
+	req.Body = input.Body
+
+	req.Examples = append([]brunoformat.ExampleBlock(nil), existing.Examples...)
+	if len(req.Examples) == 0 {
+		req.Examples = []brunoformat.ExampleBlock{{
+			Name:    input.Name + " Example",
+			Request: brunoformat.ExampleRequest{Mode: "none"},
+		}}
 	}
+	example := &req.Examples[0]
+	// The example request follows the edited request unless it was set apart
+	if example.Request.URL == "" || example.Request.URL == existing.URL {
+		example.Request.URL = input.URL
+	}
+	example.Request.Method = input.Method
+	example.Description = input.Description
+	example.Response.Headers = input.ResponseHeaders
+	example.Response.Status.Code = input.ResponseStatus.Code
+	example.Response.Status.Text = input.ResponseStatus.Text
+	example.Response.Body.Type = bodyType(input.ResponseBodyType)
+	example.Response.Body.Content = input.ResponseBody
+	example.Response.Body.File = input.ResponseBodyFile
 
 	// Initialize maps if nil
 	if req.Headers == nil {
@@ -161,12 +171,12 @@ func (s *RequestService) UpdateRequest(id string, input *dto.UpdateRequestInput)
 	if req.QueryParams == nil {
 		req.QueryParams = make(map[string]string)
 	}
-	if req.Example.Response.Headers == nil {
-		req.Example.Response.Headers = make(map[string]string)
+	if example.Response.Headers == nil {
+		example.Response.Headers = make(map[string]string)
 	}
 
 	// Write file
-	if err := s.repo.WriteFile(filePath, req); err != nil {
+	if err := s.repo.WriteFile(filePath, &req); err != nil {
 		return fmt.Errorf("failed to update request: %w", err)
 	}
 
@@ -355,3 +365,11 @@ func (s *RequestService) sortTree(node *dto.TreeNode) {
 		s.sortTree(child)
 	}
 }
+
+// bodyType returns the example body type, defaulting to json
+func bodyType(t string) string {
+	if t == "" {
+		return brunoformat.BodyJSON
+	}
+	return t
+}
@@ -0,0 +1,229 @@
+package brunoformat
+
+import (
+	"os"
+	"strings"
+)
+
+// BlockKind identifies how the contents of a block are structured
+type BlockKind int
+
+const (
+	// DictBlock holds "key: value" pairs and nested dictionaries
+	DictBlock BlockKind = iota
+	// TextBlock holds raw text such as a JSON body or a script
+	TextBlock
+	// ListBlock holds one item per line, e.g. vars:secret [ ... ]
+	ListBlock
+)
+
+// File is the syntax tree of a .bru file
+type File struct {
+	Path   string
+	Blocks []*Block
+}
+
+// Block is a named block: a top-level block or a nested dictionary
+type Block struct {
+	Name  string
+	Pos   Position
+	Kind  BlockKind
+	Pairs []*Pair  // DictBlock entries in source order
+	Text  string   // TextBlock contents, dedented
+	Items []string // ListBlock entries
+}
+
+// Pair is a single dictionary entry
+type Pair struct {
+	Key      string
+	Pos      Position
+	Disabled bool   // key was prefixed with "~"
+	Value    string // scalar or multiline string value
+	Block    *Block // nested dictionary for "key: {", nil otherwise
+}
+
+// ParseFile reads and parses a .bru file into a syntax tree
+func ParseFile(path string) (*File, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(path, string(content))
+}
+
+// Parse parses .bru source into a syntax tree.
+// Errors are returned as *ParseError carrying the file, line and column.
+func Parse(path string, src string) (*File, error) {
+	lex := newLexer(path, src)
+	file := &File{Path: path}
+
+	for {
+		tok, err := lex.nextToken(modeTopLevel)
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok.kind {
+		case tokEOF:
+			return file, nil
+		case tokListOpen:
+			block, err := parseList(lex, tok)
+			if err != nil {
+				return nil, err
+			}
+			file.Blocks = append(file.Blocks, block)
+		case tokBlockOpen:
+			if isTextBlock(tok.key) {
+				text, err := lex.readText(tok.pos)
+				if err != nil {
+					return nil, err
+				}
+				file.Blocks = append(file.Blocks, &Block{Name: tok.key, Pos: tok.pos, Kind: TextBlock, Text: text})
+				continue
+			}
+			block, err := parseDict(lex, tok)
+			if err != nil {
+				return nil, err
+			}
+			file.Blocks = append(file.Blocks, block)
+		}
+	}
+}
+
+// parseDict parses the entries of a dictionary block up to its closing brace
+func parseDict(lex *lexer, open token) (*Block, error) {
+	block := &Block{Name: open.key, Pos: open.pos, Kind: DictBlock}
+
+	for {
+		tok, err := lex.nextToken(modeDict)
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok.kind {
+		case tokEOF:
+			return nil, errorf(open.pos, "unterminated block %q: missing closing '}'", open.key)
+		case tokBlockClose:
+			return block, nil
+		case tokMapOpen:
+			nested, err := parseDict(lex, tok)
+			if err != nil {
+				return nil, err
+			}
+			pair := newPair(tok)
+			pair.Block = nested
+			block.Pairs = append(block.Pairs, pair)
+		case tokPair, tokMultiline:
+			pair := newPair(tok)
+			pair.Value = tok.value
+			block.Pairs = append(block.Pairs, pair)
+		}
+	}
+}
+
+// parseList parses the items of a list block up to its closing bracket
+func parseList(lex *lexer, open token) (*Block, error) {
+	block := &Block{Name: open.key, Pos: open.pos, Kind: ListBlock}
+
+	for {
+		tok, err := lex.nextToken(modeList)
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok.kind {
+		case tokEOF:
+			return nil, errorf(open.pos, "unterminated list %q: missing closing ']'", open.key)
+		case tokListClose:
+			return block, nil
+		case tokItem:
+			block.Items = append(block.Items, tok.key)
+		}
+	}
+}
+
+// newPair creates a pair from a key token, handling the "~" disabled prefix
+func newPair(tok token) *Pair {
+	pair := &Pair{Key: tok.key, Pos: tok.pos}
+	if strings.HasPrefix(pair.Key, "~") {
+		pair.Key = strings.TrimPrefix(pair.Key, "~")
+		pair.Disabled = true
+	}
+	return pair
+}
+
+// isTextBlock reports whether a top-level block holds raw text rather than key/value pairs
+func isTextBlock(name string) bool {
+	switch name {
+	case "docs", "tests":
+		return true
+	case "body:form-urlencoded", "body:multipart-form", "body:file":
+		return false
+	}
+	return strings.HasPrefix(name, "body:") || strings.HasPrefix(name, "script:")
+}
+
+// Block returns the first top-level block with the given name, or nil
+func (f *File) Block(name string) *Block {
+	for _, block := range f.Blocks {
+		if block.Name == name {
+			return block
+		}
+	}
+	return nil
+}
+
+// BlocksNamed returns all top-level blocks with the given name in source order
+func (f *File) BlocksNamed(name string) []*Block {
+	var blocks []*Block
+	for _, block := range f.Blocks {
+		if block.Name == name {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// Pair returns the first enabled entry with the given key, or nil
+func (b *Block) Pair(key string) *Pair {
+	if b == nil {
+		return nil
+	}
+	for _, pair := range b.Pairs {
+		if pair.Key == key && !pair.Disabled {
+			return pair
+		}
+	}
+	return nil
+}
+
+// Value returns the scalar value for key, or an empty string
+func (b *Block) Value(key string) string {
+	if pair := b.Pair(key); pair != nil {
+		return pair.Value
+	}
+	return ""
+}
+
+// Sub returns the nested dictionary for key, or nil
+func (b *Block) Sub(key string) *Block {
+	if pair := b.Pair(key); pair != nil {
+		return pair.Block
+	}
+	return nil
+}
+
+// Map returns the enabled scalar entries of the block as a map
+func (b *Block) Map() map[string]string {
+	result := make(map[string]string)
+	if b == nil {
+		return result
+	}
+	for _, pair := range b.Pairs {
+		if pair.Disabled || pair.Block != nil {
+			continue
+		}
+		result[pair.Key] = pair.Value
+	}
+	return result
+}
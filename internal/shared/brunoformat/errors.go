@@ -0,0 +1,34 @@
+package brunoformat
+
+import "fmt"
+
+// Position identifies a location in a .bru source file
+type Position struct {
+	File   string
+	Line   int // 1-based line number
+	Column int // 1-based column (byte offset within the line)
+}
+
+// String formats the position as file:line:column
+func (p Position) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// ParseError describes a syntax or semantic error found while parsing a .bru file
+type ParseError struct {
+	Pos Position
+	Msg string
+}
+
+// Error implements the error interface
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// errorf creates a ParseError at the given position
+func errorf(pos Position, format string, args ...interface{}) *ParseError {
+	return &ParseError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,247 @@
+package brunoformat
+
+import (
+	"regexp"
+	"strings"
+)
+
+// tokenKind identifies the type of a lexical token
+type tokenKind int
+
+const (
+	tokEOF        tokenKind = iota
+	tokBlockOpen            // name {
+	tokListOpen             // name [
+	tokBlockClose           // }
+	tokListClose            // ]
+	tokPair                 // key: value
+	tokMapOpen              // key: {
+	tokMultiline            // key: '''...'''
+	tokItem                 // list entry
+)
+
+// lexMode selects how the next line is tokenized
+type lexMode int
+
+const (
+	modeTopLevel lexMode = iota
+	modeDict
+	modeList
+)
+
+// token is a single lexical element of a .bru file
+type token struct {
+	kind  tokenKind
+	pos   Position
+	key   string // block name, dictionary key or list item
+	value string // scalar or multiline string value
+}
+
+// tripleQuote delimits multiline string values
+const tripleQuote = "'''"
+
+// headerRe matches top-level block headers like "meta {" or "vars:secret ["
+var headerRe = regexp.MustCompile(`^([^\s{}\[\]]+)\s*([{\[])$`)
+
+// lexer splits .bru source into tokens line by line.
+// Bru is line-oriented: every token starts on its own line, and the
+// parser tells the lexer which mode the next line should be read in.
+type lexer struct {
+	file  string
+	lines []string
+	next  int // index of the next unread line
+}
+
+// newLexer creates a lexer for the given source
+func newLexer(file string, src string) *lexer {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	return &lexer{
+		file:  file,
+		lines: strings.Split(src, "\n"),
+	}
+}
+
+// position returns the position of the given column on a zero-based line index
+func (l *lexer) position(index, column int) Position {
+	return Position{File: l.file, Line: index + 1, Column: column}
+}
+
+// skipBlank advances past empty lines
+func (l *lexer) skipBlank() {
+	for l.next < len(l.lines) && strings.TrimSpace(l.lines[l.next]) == "" {
+		l.next++
+	}
+}
+
+// nextToken reads the next token in the given mode
+func (l *lexer) nextToken(mode lexMode) (token, error) {
+	l.skipBlank()
+	if l.next >= len(l.lines) {
+		return token{kind: tokEOF, pos: l.position(len(l.lines)-1, 1)}, nil
+	}
+
+	index := l.next
+	line := l.lines[index]
+	trimmed := strings.TrimSpace(line)
+	pos := l.position(index, indentOf(line)+1)
+	l.next++
+
+	switch mode {
+	case modeTopLevel:
+		match := headerRe.FindStringSubmatch(trimmed)
+		if match == nil {
+			return token{}, errorf(pos, "expected block header like \"name {\", found %q", trimmed)
+		}
+		if match[2] == "[" {
+			return token{kind: tokListOpen, pos: pos, key: match[1]}, nil
+		}
+		return token{kind: tokBlockOpen, pos: pos, key: match[1]}, nil
+
+	case modeList:
+		if trimmed == "]" {
+			return token{kind: tokListClose, pos: pos}, nil
+		}
+		return token{kind: tokItem, pos: pos, key: strings.TrimSuffix(trimmed, ",")}, nil
+	}
+
+	// Dictionary mode
+	if trimmed == "}" {
+		return token{kind: tokBlockClose, pos: pos}, nil
+	}
+
+	colon := strings.Index(trimmed, ":")
+	if colon < 0 {
+		// Legacy nested block syntax without a colon: "headers {"
+		if strings.HasSuffix(trimmed, "{") {
+			key := strings.TrimSpace(strings.TrimSuffix(trimmed, "{"))
+			if key != "" {
+				return token{kind: tokMapOpen, pos: pos, key: key}, nil
+			}
+		}
+		return token{}, errorf(pos, "expected \"key: value\", found %q", trimmed)
+	}
+
+	key := strings.TrimSpace(trimmed[:colon])
+	value := strings.TrimSpace(trimmed[colon+1:])
+	if key == "" {
+		return token{}, errorf(pos, "missing key before ':'")
+	}
+
+	switch {
+	case value == "{":
+		return token{kind: tokMapOpen, pos: pos, key: key}, nil
+	case strings.HasPrefix(value, tripleQuote):
+		rest := value[len(tripleQuote):]
+		if end := strings.Index(rest, tripleQuote); end >= 0 {
+			return token{kind: tokMultiline, pos: pos, key: key, value: rest[:end]}, nil
+		}
+		text, err := l.readMultiline(pos, rest)
+		if err != nil {
+			return token{}, err
+		}
+		return token{kind: tokMultiline, pos: pos, key: key, value: text}, nil
+	case value == "" && l.peekTrimmed() == tripleQuote:
+		// Opening quotes on the line after the key
+		l.next++
+		text, err := l.readMultiline(pos, "")
+		if err != nil {
+			return token{}, err
+		}
+		return token{kind: tokMultiline, pos: pos, key: key, value: text}, nil
+	}
+
+	return token{kind: tokPair, pos: pos, key: key, value: value}, nil
+}
+
+// peekTrimmed returns the next non-blank line, trimmed, without consuming it
+func (l *lexer) peekTrimmed() string {
+	for i := l.next; i < len(l.lines); i++ {
+		if trimmed := strings.TrimSpace(l.lines[i]); trimmed != "" {
+			return trimmed
+		}
+	}
+	return ""
+}
+
+// readMultiline consumes lines up to the closing triple quotes and returns the dedented text.
+// first holds any text that followed the opening quotes on the key line.
+func (l *lexer) readMultiline(start Position, first string) (string, error) {
+	var lines []string
+	if strings.TrimSpace(first) != "" {
+		lines = append(lines, first)
+	}
+
+	for l.next < len(l.lines) {
+		line := l.lines[l.next]
+		l.next++
+
+		trimmed := strings.TrimSpace(line)
+		if strings.HasSuffix(trimmed, tripleQuote) {
+			if before := strings.TrimSuffix(strings.TrimRight(line, " \t"), tripleQuote); strings.TrimSpace(before) != "" {
+				lines = append(lines, before)
+			}
+			return dedent(lines), nil
+		}
+		lines = append(lines, line)
+	}
+
+	return "", errorf(start, "unterminated multiline string: missing closing %s", tripleQuote)
+}
+
+// readText consumes the body of a text block (body:json, script:*, docs, ...).
+// Text blocks are closed by a "}" in the first column. A closing brace only
+// counts when it is followed by another block header or the end of the file,
+// so unindented JSON whose last line is "}" still parses.
+func (l *lexer) readText(start Position) (string, error) {
+	var lines []string
+
+	for l.next < len(l.lines) {
+		line := l.lines[l.next]
+		l.next++
+
+		if strings.TrimRight(line, " \t") == "}" && l.atBlockBoundary() {
+			return dedent(lines), nil
+		}
+		lines = append(lines, line)
+	}
+
+	return "", errorf(start, "unterminated block: missing closing '}'")
+}
+
+// atBlockBoundary reports whether the remaining input starts with a new block or is empty
+func (l *lexer) atBlockBoundary() bool {
+	next := l.peekTrimmed()
+	return next == "" || headerRe.MatchString(next)
+}
+
+// indentOf returns the number of leading whitespace bytes in a line
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// dedent removes the common leading indentation from lines and trims surrounding blank lines
+func dedent(lines []string) string {
+	common := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if indent := indentOf(line); common < 0 || indent < common {
+			common = indent
+		}
+	}
+
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if common > 0 {
+			if len(line) >= common {
+				line = line[common:]
+			} else {
+				line = strings.TrimLeft(line, " \t")
+			}
+		}
+		out[i] = strings.TrimRight(line, " \t")
+	}
+
+	return strings.Trim(strings.Join(out, "\n"), "\n")
+}
@@ -2,406 +2,440 @@ package brunoformat
 
 import (
 	"fmt"
-	"os"
+	"slices"
 	"strconv"
 	"strings"
 )
 
+// httpMethods lists the method block names recognised in a .bru file
+var httpMethods = []string{"get", "post", "put", "delete", "patch", "options", "head"}
+
 // ParseBrunoFile parses a .bru file and returns a BrunoRequest
 func ParseBrunoFile(filepath string) (*BrunoRequest, error) {
-	content, err := os.ReadFile(filepath)
+	file, err := ParseFile(filepath)
+	if err != nil {
+		if _, ok := err.(*ParseError); ok {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read file %s: %w", filepath, err)
+	}
+
+	return DecodeRequest(file)
+}
+
+// ParseFolderFile parses a folder.bru file and returns its FolderSettings
+func ParseFolderFile(filepath string) (*FolderSettings, error) {
+	file, err := ParseFile(filepath)
 	if err != nil {
+		if _, ok := err.(*ParseError); ok {
+			return nil, err
+		}
 		return nil, fmt.Errorf("failed to read file %s: %w", filepath, err)
 	}
 
+	folder := &FolderSettings{FilePath: file.Path}
+
+	if block := file.Block("meta"); block != nil {
+		meta, err := parseMetaBlock(block)
+		if err != nil {
+			return nil, err
+		}
+		folder.Meta = meta
+	}
+
+	if block := file.Block("mock"); block != nil {
+		settings, err := parseMockBlock(block, nil)
+		if err != nil {
+			return nil, err
+		}
+		folder.Mock = settings
+	}
+
+	return folder, nil
+}
+
+// ReparseRequest validates a request built in code, e.g. decoded from JSON,
+// by serializing it and decoding the result like a .bru file. The returned
+// request is exactly what loading the serialized file would produce.
+func ReparseRequest(req *BrunoRequest) (*BrunoRequest, error) {
+	file, err := Parse(req.FilePath, NewSerializer().Serialize(req))
+	if err != nil {
+		return nil, err
+	}
+	return DecodeRequest(file)
+}
+
+// DecodeRequest builds a BrunoRequest from a parsed .bru syntax tree
+func DecodeRequest(file *File) (*BrunoRequest, error) {
 	req := &BrunoRequest{
-		FilePath:    filepath,
+		FilePath:    file.Path,
 		Headers:     make(map[string]string),
 		QueryParams: make(map[string]string),
 	}
 
-	// Extract all blocks from the file
-	blocks := extractBlocks(string(content))
-
 	// Parse meta block
-	if metaContent, ok := blocks["meta"]; ok {
-		req.Meta = parseMetaBlock(metaContent)
+	if block := file.Block("meta"); block != nil {
+		meta, err := parseMetaBlock(block)
+		if err != nil {
+			return nil, err
+		}
+		req.Meta = meta
 	}
 
 	// Parse HTTP method blocks
-	for _, method := range []string{"get", "post", "put", "delete", "patch"} {
-		if methodContent, ok := blocks[method]; ok {
+	for _, method := range httpMethods {
+		if block := file.Block(method); block != nil {
 			req.Method = strings.ToUpper(method)
-			req.URL = parseMethodBlock(methodContent)
+			req.URL = block.Value("url")
 			break
 		}
 	}
 
 	// Parse headers block
-	if headersContent, ok := blocks["headers"]; ok {
-		req.Headers = parseKeyValueBlock(headersContent)
+	if block := file.Block("headers"); block != nil {
+		req.Headers = block.Map()
 	}
 
 	// Parse params:query block
-	if paramsContent, ok := blocks["params:query"]; ok {
-		req.QueryParams = parseKeyValueBlock(paramsContent)
+	if block := file.Block("params:query"); block != nil {
+		req.QueryParams = block.Map()
 	}
 
 	// Parse body:json block
-	if bodyContent, ok := blocks["body:json"]; ok {
-		req.Body = strings.TrimSpace(bodyContent)
+	if block := file.Block("body:json"); block != nil {
+		req.Body = block.Text
+	}
+
+	// Parse example blocks in declaration order
+	for _, block := range file.BlocksNamed("example") {
+		example, err := parseExampleBlock(block)
+		if err != nil {
+			return nil, err
+		}
+		if example.Name != "" && req.FindExample(example.Name) != nil {
+			return nil, errorf(block.Pos, "duplicate example name %q", example.Name)
+		}
+		req.Examples = append(req.Examples, example)
+	}
+
+	// Generate default example block if none exists
+	if len(req.Examples) == 0 {
+		req.Examples = append(req.Examples, NewDefaultExampleBlock(req.Method, req.URL))
 	}
 
-	// Parse example block
-	if exampleContent, ok := blocks["example"]; ok {
-		example, err := parseExampleBlock(exampleContent)
+	// Parse mock block (needs the examples to validate references)
+	if block := file.Block("mock"); block != nil {
+		settings, err := parseMockBlock(block, req)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse example block: %w", err)
+			return nil, err
 		}
-		req.Example = example
-	} else {
-		// Generate default example block if none exists
-		req.Example = NewDefaultExampleBlock(req.Method, req.URL)
+		req.Mock = settings
 	}
 
 	return req, nil
 }
 
-// extractBlocks extracts all top-level blocks from the .bru file content
-func extractBlocks(content string) map[string]string {
-	blocks := make(map[string]string)
-
-	// Regex to match block_name { ... content ... }
-	// This handles nested braces by counting them
-	lines := strings.Split(content, "\n")
-	var currentBlock string
-	var blockContent strings.Builder
-	braceCount := 0
-	inBlock := false
-
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-
-		// Check if this line starts a new block
-		if !inBlock && strings.Contains(trimmed, "{") {
-			parts := strings.SplitN(trimmed, "{", 2)
-			blockName := strings.TrimSpace(parts[0])
-			// Strip trailing colon if present (e.g., "request:" -> "request")
-			blockName = strings.TrimSuffix(blockName, ":")
-			if blockName != "" {
-				currentBlock = blockName
-				inBlock = true
-				braceCount = 1
-
-				// Add the rest of the line after the opening brace
-				rest := strings.TrimSpace(parts[1])
-				if rest != "" {
-					blockContent.WriteString(rest)
-					blockContent.WriteString("\n")
-				}
-
-				// Count additional braces on this line
-				braceCount += strings.Count(rest, "{")
-				braceCount -= strings.Count(rest, "}")
+// parseMockBlock parses mock server settings. req is nil for folder.bru
+// files, which cannot declare sequences.
+func parseMockBlock(block *Block, req *BrunoRequest) (MockSettings, error) {
+	settings := MockSettings{SequenceMode: SequenceStick}
 
-				if braceCount == 0 {
-					blocks[currentBlock] = strings.TrimSpace(blockContent.String())
-					blockContent.Reset()
-					inBlock = false
-				}
+	if pair := block.Pair("sequence"); pair != nil {
+		if req == nil {
+			return settings, errorf(pair.Pos, "sequence is only allowed in request files")
+		}
+		for _, name := range strings.Split(pair.Value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
 				continue
 			}
+			if req.FindExample(name) == nil {
+				return settings, errorf(pair.Pos, "sequence refers to unknown example %q", name)
+			}
+			settings.Sequence = append(settings.Sequence, name)
 		}
+	}
 
-		// If we're in a block, accumulate content
-		if inBlock {
-			// Count braces in this line
-			openCount := strings.Count(line, "{")
-			closeCount := strings.Count(line, "}")
+	if pair := block.Pair("sequenceMode"); pair != nil {
+		if pair.Value != SequenceStick && pair.Value != SequenceRepeat {
+			return settings, errorf(pair.Pos, "invalid sequenceMode %q: expected %s or %s", pair.Value, SequenceStick, SequenceRepeat)
+		}
+		settings.SequenceMode = pair.Value
+	}
 
-			// Add line to content ONLY if it won't close the block
-			if braceCount+openCount-closeCount > 0 {
-				blockContent.WriteString(line)
-				blockContent.WriteString("\n")
-			}
+	settings.Delay = block.Value("delay")
 
-			braceCount += openCount
-			braceCount -= closeCount
+	if pair := block.Pair("proxy"); pair != nil {
+		proxy, err := strconv.ParseBool(pair.Value)
+		if err != nil {
+			return settings, errorf(pair.Pos, "invalid proxy %q: expected true or false", pair.Value)
+		}
+		settings.Proxy = &proxy
+	}
 
-			if braceCount == 0 {
-				blocks[currentBlock] = strings.TrimSpace(blockContent.String())
-				blockContent.Reset()
-				inBlock = false
-			}
+	if pair := block.Pair("priority"); pair != nil {
+		priority, err := strconv.Atoi(pair.Value)
+		if err != nil {
+			return settings, errorf(pair.Pos, "invalid priority %q: expected an integer", pair.Value)
 		}
+		settings.Priority = priority
 	}
 
-	return blocks
-}
+	if pair := block.Pair("paginate"); pair != nil {
+		if pair.Value != PaginatePage && pair.Value != PaginateOffset && pair.Value != PaginateCursor {
+			return settings, errorf(pair.Pos, "invalid paginate %q: expected %s, %s or %s", pair.Value, PaginatePage, PaginateOffset, PaginateCursor)
+		}
+		settings.Paginate = pair.Value
+	}
 
-// parseMetaBlock parses the meta block
-func parseMetaBlock(content string) MetaBlock {
-	meta := MetaBlock{}
-
-	// Parse key: value pairs
-	lines := strings.Split(content, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || line == "}" {
-			continue
+	if pair := block.Pair("pageSize"); pair != nil {
+		size, err := strconv.Atoi(pair.Value)
+		if err != nil || size < 1 {
+			return settings, errorf(pair.Pos, "invalid pageSize %q: expected a positive integer", pair.Value)
 		}
+		settings.PageSize = size
+	}
 
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) != 2 {
-			continue
+	if pair := block.Pair("pageFormat"); pair != nil {
+		if pair.Value != PageFormatHeaders && pair.Value != PageFormatEnvelope {
+			return settings, errorf(pair.Pos, "invalid pageFormat %q: expected %s or %s", pair.Value, PageFormatHeaders, PageFormatEnvelope)
 		}
+		settings.PageFormat = pair.Value
+	}
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
+	if pair := block.Pair("filter"); pair != nil {
+		filter, err := strconv.ParseBool(pair.Value)
+		if err != nil {
+			return settings, errorf(pair.Pos, "invalid filter %q: expected true or false", pair.Value)
+		}
+		settings.Filter = &filter
+	}
 
-		switch key {
-		case "name":
-			meta.Name = value
-		case "type":
-			meta.Type = value
-		case "seq":
-			if seq, err := strconv.Atoi(value); err == nil {
-				meta.Seq = seq
-			}
+	if pair := block.Pair("sort"); pair != nil {
+		sort, err := strconv.ParseBool(pair.Value)
+		if err != nil {
+			return settings, errorf(pair.Pos, "invalid sort %q: expected true or false", pair.Value)
 		}
+		settings.Sort = &sort
 	}
 
-	return meta
+	settings.RateLimit = block.Value("rateLimit")
+	settings.RateLimitKey = block.Value("rateLimitKey")
+	settings.RateLimitAlgorithm = block.Value("rateLimitAlgorithm")
+
+	return settings, nil
 }
 
-// parseMethodBlock parses the HTTP method block (get, post, etc.)
-func parseMethodBlock(content string) string {
-	// Look for url: value
-	lines := strings.Split(content, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "url:") {
-			url := strings.TrimSpace(strings.TrimPrefix(line, "url:"))
-			return url
+// parseMetaBlock parses the meta block
+func parseMetaBlock(block *Block) (MetaBlock, error) {
+	meta := MetaBlock{
+		Name: block.Value("name"),
+		Type: block.Value("type"),
+	}
+
+	if pair := block.Pair("seq"); pair != nil {
+		seq, err := strconv.Atoi(pair.Value)
+		if err != nil {
+			return meta, errorf(pair.Pos, "invalid seq %q: must be an integer", pair.Value)
 		}
+		meta.Seq = seq
 	}
-	return ""
+
+	return meta, nil
 }
 
 // parseExampleBlock parses the example block
-func parseExampleBlock(content string) (ExampleBlock, error) {
+func parseExampleBlock(block *Block) (ExampleBlock, error) {
 	example := ExampleBlock{
+		Name:        block.Value("name"),
+		Description: block.Value("description"),
+		Scenario:    block.Value("scenario"),
+		Delay:       block.Value("delay"),
 		Response: ExampleResponse{
 			Headers: make(map[string]string),
 		},
 	}
 
-	// Parse top-level key:value pairs and extract nested blocks
-	blocks := extractBlocks(content)
-
-	// Parse top-level fields (name, description)
-	lines := strings.Split(content, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.Contains(line, "{") {
-			continue
-		}
-
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) != 2 {
-			continue
+	// Parse match block
+	if match := block.Sub("match"); match != nil {
+		rules, err := parseMatchBlock(match)
+		if err != nil {
+			return example, err
 		}
+		example.Match = rules
+	}
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-
-		switch key {
-		case "name":
-			example.Name = value
-		case "description":
-			example.Description = value
+	// Parse fault block
+	if fault := block.Sub("fault"); fault != nil {
+		settings, err := parseFaultBlock(fault)
+		if err != nil {
+			return example, err
 		}
+		example.Fault = settings
 	}
 
 	// Parse request block
-	if requestContent, ok := blocks["request"]; ok {
-		example.Request = parseExampleRequestBlock(requestContent)
+	if request := block.Sub("request"); request != nil {
+		example.Request = ExampleRequest{
+			URL:    request.Value("url"),
+			Method: request.Value("method"),
+			Mode:   request.Value("mode"),
+		}
 	}
 
 	// Parse response block
-	if responseContent, ok := blocks["response"]; ok {
-		response, err := parseExampleResponseBlock(responseContent)
+	if response := block.Sub("response"); response != nil {
+		parsed, err := parseExampleResponseBlock(response)
 		if err != nil {
 			return example, err
 		}
-		example.Response = response
+		example.Response = parsed
 	}
 
 	return example, nil
 }
 
-// parseExampleRequestBlock parses the request block within example
-func parseExampleRequestBlock(content string) ExampleRequest {
-	request := ExampleRequest{}
+// parseMatchBlock parses the match conditions of an example.
+// Keys are "query.<name>", "headers.<name>" or "body.<path>"; values are
+// "<operator> <operand>", and a value without a known operator means "eq".
+func parseMatchBlock(block *Block) ([]MatchRule, error) {
+	var rules []MatchRule
 
-	lines := strings.Split(content, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || line == "}" {
+	for _, pair := range block.Pairs {
+		if pair.Disabled {
 			continue
 		}
-
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) != 2 {
-			continue
+		if pair.Block != nil {
+			return nil, errorf(pair.Pos, "match condition %q must be a value, not a block", pair.Key)
 		}
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
+		source, key, _ := strings.Cut(pair.Key, ".")
+		if source == "header" {
+			source = MatchHeaders
+		}
+		if source != MatchQuery && source != MatchHeaders && source != MatchBody {
+			return nil, errorf(pair.Pos, "invalid match target %q: expected query.<name>, headers.<name> or body.<path>", pair.Key)
+		}
+		if key == "" {
+			return nil, errorf(pair.Pos, "match target %q is missing a name", pair.Key)
+		}
 
-		switch key {
-		case "url":
-			request.URL = value
-		case "method":
-			request.Method = value
-		case "mode":
-			request.Mode = value
+		rule := MatchRule{Source: source, Key: key, Operator: "eq", Value: pair.Value, Pos: pair.Pos}
+		if op, operand, _ := strings.Cut(pair.Value, " "); slices.Contains(MatchOperators, op) {
+			rule.Operator = op
+			rule.Value = strings.TrimSpace(operand)
 		}
+		rules = append(rules, rule)
 	}
 
-	return request
+	return rules, nil
 }
 
 // parseExampleResponseBlock parses the response block within example
-func parseExampleResponseBlock(content string) (ExampleResponse, error) {
+func parseExampleResponseBlock(block *Block) (ExampleResponse, error) {
 	response := ExampleResponse{
 		Headers: make(map[string]string),
 	}
 
-	// Extract nested blocks (headers, status, body)
-	blocks := extractBlocks(content)
-
 	// Parse headers block
-	if headersContent, ok := blocks["headers"]; ok {
-		response.Headers = parseKeyValueBlock(headersContent)
+	if headers := block.Sub("headers"); headers != nil {
+		response.Headers = headers.Map()
 	}
 
 	// Parse status block
-	if statusContent, ok := blocks["status"]; ok {
-		response.Status = parseStatusBlock(statusContent)
-	}
-
-	// Parse body block
-	if bodyContent, ok := blocks["body"]; ok {
-		body, err := parseBodyBlock(bodyContent)
+	if status := block.Sub("status"); status != nil {
+		parsed, err := parseStatusBlock(status)
 		if err != nil {
 			return response, err
 		}
-		response.Body = body
+		response.Status = parsed
 	}
 
-	return response, nil
-}
-
-// parseKeyValueBlock parses a block with key: value pairs
-func parseKeyValueBlock(content string) map[string]string {
-	result := make(map[string]string)
-
-	lines := strings.Split(content, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || line == "}" {
-			continue
+	// Parse body block
+	if body := block.Sub("body"); body != nil {
+		response.Body = ExampleBody{
+			Type:    body.Value("type"),
+			Content: body.Value("content"),
+			File:    body.Value("file"),
 		}
-
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) != 2 {
-			continue
+		if pair := body.Pair("type"); pair != nil && pair.Value != "" && !slices.Contains(BodyTypes, pair.Value) {
+			return response, errorf(pair.Pos, "invalid body type %q: expected one of %s", pair.Value, strings.Join(BodyTypes, ", "))
 		}
-
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-		result[key] = value
 	}
 
-	return result
+	return response, nil
 }
 
-// parseStatusBlock parses the status block
-func parseStatusBlock(content string) ExampleStatus {
-	status := ExampleStatus{}
-
-	lines := strings.Split(content, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || line == "}" {
+// parseFaultBlock parses the fault block of an example, e.g.
+//
+//	fault: {
+//	  error: 10% 503
+//	  drop: 5%
+//	}
+func parseFaultBlock(block *Block) (FaultSettings, error) {
+	var fault FaultSettings
+
+	for _, pair := range block.Pairs {
+		if pair.Disabled {
 			continue
 		}
-
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) != 2 {
-			continue
+		if pair.Block != nil {
+			return fault, errorf(pair.Pos, "fault %q must be a value, not a block", pair.Key)
 		}
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
+		fields := strings.Fields(pair.Value)
+		if len(fields) == 0 {
+			return fault, errorf(pair.Pos, "fault %q needs a rate, e.g. 10%%", pair.Key)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSuffix(fields[0], "%"), 64)
+		if err != nil || rate < 0 || rate > 100 {
+			return fault, errorf(pair.Pos, "invalid fault rate %q: expected a percentage between 0 and 100", fields[0])
+		}
+		if len(fields) > 1 && pair.Key != "error" {
+			return fault, errorf(pair.Pos, "fault %q takes only a rate", pair.Key)
+		}
 
-		switch key {
-		case "code":
-			if code, err := strconv.Atoi(value); err == nil {
-				status.Code = code
+		switch pair.Key {
+		case "error":
+			fault.ErrorRate = rate
+			if len(fields) > 1 {
+				code, err := strconv.Atoi(fields[1])
+				if err != nil || code < 100 || code > 999 {
+					return fault, errorf(pair.Pos, "invalid fault status %q", fields[1])
+				}
+				fault.ErrorStatus = code
 			}
-		case "text":
-			status.Text = value
+		case "drop":
+			fault.DropRate = rate
+		case "stall":
+			fault.StallRate = rate
+		case "truncate":
+			fault.TruncateRate = rate
+		default:
+			return fault, errorf(pair.Pos, "unknown fault %q: expected error, drop, stall or truncate", pair.Key)
 		}
 	}
 
-	return status
-}
-
-// parseBodyBlock parses the body block with triple-quoted content
-func parseBodyBlock(content string) (ExampleBody, error) {
-	body := ExampleBody{}
-
-	// Look for type and content fields
-	lines := strings.Split(content, "\n")
-	inTripleQuote := false
-	var contentBuilder strings.Builder
-
-	for i, line := range lines {
-		trimmed := strings.TrimSpace(line)
+	if fault.ErrorRate+fault.DropRate+fault.StallRate+fault.TruncateRate > 100 {
+		return fault, errorf(block.Pos, "fault rates add up to more than 100%%")
+	}
 
-		// Parse type field
-		if strings.HasPrefix(trimmed, "type:") {
-			body.Type = strings.TrimSpace(strings.TrimPrefix(trimmed, "type:"))
-			continue
-		}
+	return fault, nil
+}
 
-		// Check for content field with triple quotes
-		if strings.HasPrefix(trimmed, "content:") {
-			// Check if triple quotes are on the same line or next line
-			if strings.Contains(line, "'''") {
-				inTripleQuote = true
-				continue
-			}
-			// Check next line for triple quotes
-			if i+1 < len(lines) && strings.Contains(strings.TrimSpace(lines[i+1]), "'''") {
-				inTripleQuote = true
-				continue
-			}
-		}
+// parseStatusBlock parses the status block
+func parseStatusBlock(block *Block) (ExampleStatus, error) {
+	status := ExampleStatus{
+		Text: block.Value("text"),
+	}
 
-		// Handle triple-quoted content
-		if inTripleQuote {
-			if strings.Contains(trimmed, "'''") {
-				inTripleQuote = false
-				continue
-			}
-			contentBuilder.WriteString(line)
-			contentBuilder.WriteString("\n")
+	if pair := block.Pair("code"); pair != nil {
+		code, err := strconv.Atoi(pair.Value)
+		if err != nil || code < 100 || code > 999 {
+			return status, errorf(pair.Pos, "invalid status code %q", pair.Value)
 		}
+		status.Code = code
 	}
 
-	body.Content = strings.TrimSpace(contentBuilder.String())
-	return body, nil
+	return status, nil
 }
@@ -0,0 +1,296 @@
+package brunoformat
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeRequest(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		check func(t *testing.T, req *BrunoRequest)
+	}{
+		{
+			name: "request blocks",
+			src: `meta {
+  name: List users
+  type: http
+  seq: 2
+}
+
+get {
+  url: {{baseUrl}}/users
+}
+
+headers {
+  Accept: application/json
+  ~X-Debug: 1
+}
+
+params:query {
+  page: 2
+}
+
+body:json {
+  {"name": "Ada"}
+}
+`,
+			check: func(t *testing.T, req *BrunoRequest) {
+				want := MetaBlock{Name: "List users", Type: "http", Seq: 2}
+				if req.Meta != want {
+					t.Errorf("Meta = %+v, want %+v", req.Meta, want)
+				}
+				if req.Method != "GET" || req.URL != "{{baseUrl}}/users" {
+					t.Errorf("got %s %s, want GET {{baseUrl}}/users", req.Method, req.URL)
+				}
+				if !reflect.DeepEqual(req.Headers, map[string]string{"Accept": "application/json"}) {
+					t.Errorf("Headers = %v, want only the enabled header", req.Headers)
+				}
+				if req.QueryParams["page"] != "2" {
+					t.Errorf("QueryParams = %v, want page=2", req.QueryParams)
+				}
+				if req.Body != `{"name": "Ada"}` {
+					t.Errorf("Body = %q", req.Body)
+				}
+			},
+		},
+		{
+			name: "default example",
+			src: `post {
+  url: {{baseUrl}}/users
+}
+`,
+			check: func(t *testing.T, req *BrunoRequest) {
+				if len(req.Examples) != 1 {
+					t.Fatalf("got %d examples, want a generated default", len(req.Examples))
+				}
+				if req.Examples[0].Request.Method != "POST" {
+					t.Errorf("default example method = %q, want POST", req.Examples[0].Request.Method)
+				}
+			},
+		},
+		{
+			name: "examples and match rules",
+			src: `get {
+  url: {{baseUrl}}/users/:id
+}
+
+example {
+  name: Admin
+  scenario: admins
+  delay: 100ms
+
+  match: {
+    query.role: admin
+    headers.x-tenant: in a, b
+    body.user.age: gte 18
+  }
+
+  response: {
+    headers: {
+      content-type: application/json
+    }
+
+    status: {
+      code: 200
+      text: OK
+    }
+
+    body: {
+      type: json
+      content: '''
+        {
+          "id": 1
+        }
+      '''
+    }
+  }
+}
+
+example {
+  name: Missing
+
+  response: {
+    status: {
+      code: 404
+      text: Not Found
+    }
+  }
+}
+`,
+			check: func(t *testing.T, req *BrunoRequest) {
+				if got := req.ExampleNames(); !reflect.DeepEqual(got, []string{"Admin", "Missing"}) {
+					t.Fatalf("ExampleNames() = %v", got)
+				}
+				admin := req.Examples[0]
+				if admin.Scenario != "admins" || admin.Delay != "100ms" {
+					t.Errorf("scenario %q, delay %q", admin.Scenario, admin.Delay)
+				}
+				var rules []string
+				for _, rule := range admin.Match {
+					rules = append(rules, rule.String())
+				}
+				want := []string{"query.role: eq admin", "headers.x-tenant: in a, b", "body.user.age: gte 18"}
+				if !reflect.DeepEqual(rules, want) {
+					t.Errorf("Match = %q, want %q", rules, want)
+				}
+				if admin.Response.Body.Content != "{\n  \"id\": 1\n}" {
+					t.Errorf("body content not dedented: %q", admin.Response.Body.Content)
+				}
+				if req.Examples[1].Response.Status.Code != 404 {
+					t.Errorf("second example status = %d, want 404", req.Examples[1].Response.Status.Code)
+				}
+			},
+		},
+		{
+			name: "mock settings",
+			src: `get {
+  url: {{baseUrl}}/jobs/1
+}
+
+mock {
+  sequence: pending, done
+  sequenceMode: repeat
+  priority: -2
+  proxy: false
+  rateLimit: 5/1m
+}
+
+example {
+  name: pending
+}
+
+example {
+  name: done
+}
+`,
+			check: func(t *testing.T, req *BrunoRequest) {
+				mock := req.Mock
+				if !reflect.DeepEqual(mock.Sequence, []string{"pending", "done"}) || mock.SequenceMode != SequenceRepeat {
+					t.Errorf("sequence %v %q", mock.Sequence, mock.SequenceMode)
+				}
+				if mock.Priority != -2 {
+					t.Errorf("Priority = %d, want -2", mock.Priority)
+				}
+				if mock.Proxy == nil || *mock.Proxy {
+					t.Errorf("Proxy = %v, want explicit false", mock.Proxy)
+				}
+				if mock.RateLimit != "5/1m" {
+					t.Errorf("RateLimit = %q", mock.RateLimit)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := Parse("test.bru", tt.src)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			req, err := DecodeRequest(file)
+			if err != nil {
+				t.Fatalf("DecodeRequest: %v", err)
+			}
+			tt.check(t, req)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		src    string
+		line   int
+		column int
+		msg    string
+	}{
+		{
+			name:   "text outside a block",
+			src:    "get {\n  url: /a\n}\nstray\n",
+			line:   4,
+			column: 1,
+			msg:    `expected block header like "name {", found "stray"`,
+		},
+		{
+			name:   "unterminated block",
+			src:    "meta {\n  name: a\n",
+			line:   1,
+			column: 1,
+			msg:    `unterminated block "meta"`,
+		},
+		{
+			name:   "pair without colon",
+			src:    "headers {\n  Accept application/json\n}\n",
+			line:   2,
+			column: 3,
+			msg:    `expected "key: value"`,
+		},
+		{
+			name:   "missing key",
+			src:    "headers {\n    : value\n}\n",
+			line:   2,
+			column: 5,
+			msg:    "missing key before ':'",
+		},
+		{
+			name:   "unterminated multiline string",
+			src:    "example {\n  response: {\n    body: {\n      content: '''\n        {}\n",
+			line:   4,
+			column: 7,
+			msg:    "unterminated multiline string",
+		},
+		{
+			name:   "unterminated list",
+			src:    "tags [\n  a\n",
+			line:   1,
+			column: 1,
+			msg:    `unterminated list "tags"`,
+		},
+		{
+			name:   "invalid seq",
+			src:    "meta {\n  name: a\n  seq: first\n}\n",
+			line:   3,
+			column: 3,
+			msg:    `invalid seq "first"`,
+		},
+		{
+			name:   "unknown sequence example",
+			src:    "get {\n  url: /a\n}\n\nmock {\n  sequence: nope\n}\n",
+			line:   6,
+			column: 3,
+			msg:    `sequence refers to unknown example "nope"`,
+		},
+		{
+			name:   "duplicate example name",
+			src:    "get {\n  url: /a\n}\n\nexample {\n  name: A\n}\n\nexample {\n  name: a\n}\n",
+			line:   9,
+			column: 1,
+			msg:    `duplicate example name "a"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := Parse("broken.bru", tt.src)
+			if err == nil {
+				_, err = DecodeRequest(file)
+			}
+
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("got error %v, want a *ParseError", err)
+			}
+			want := Position{File: "broken.bru", Line: tt.line, Column: tt.column}
+			if parseErr.Pos != want {
+				t.Errorf("position = %s, want %s", parseErr.Pos, want)
+			}
+			if !strings.Contains(parseErr.Msg, tt.msg) {
+				t.Errorf("message = %q, want it to contain %q", parseErr.Msg, tt.msg)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package brunoformat
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -50,53 +51,177 @@ func (s *Serializer) Serialize(req *BrunoRequest) string {
 	// Body block (request body)
 	if req.Body != "" {
 		sb.WriteString("body:json {\n")
-		sb.WriteString(req.Body)
+		sb.WriteString(indent(req.Body, "  "))
 		sb.WriteString("\n}\n\n")
 	}
 
-	// Example block
+	// Mock server settings
+	if len(req.Mock.Sequence) > 0 || req.Mock.Delay != "" || req.Mock.Proxy != nil || req.Mock.Priority != 0 ||
+		req.Mock.Paginate != "" || req.Mock.PageSize != 0 || req.Mock.PageFormat != "" ||
+		req.Mock.Filter != nil || req.Mock.Sort != nil ||
+		req.Mock.RateLimit != "" || req.Mock.RateLimitKey != "" || req.Mock.RateLimitAlgorithm != "" {
+		sb.WriteString("mock {\n")
+		if len(req.Mock.Sequence) > 0 {
+			sb.WriteString(fmt.Sprintf("  sequence: %s\n", strings.Join(req.Mock.Sequence, ", ")))
+			if req.Mock.SequenceMode != "" {
+				sb.WriteString(fmt.Sprintf("  sequenceMode: %s\n", req.Mock.SequenceMode))
+			}
+		}
+		if req.Mock.Delay != "" {
+			sb.WriteString(fmt.Sprintf("  delay: %s\n", req.Mock.Delay))
+		}
+		if req.Mock.Proxy != nil {
+			sb.WriteString(fmt.Sprintf("  proxy: %t\n", *req.Mock.Proxy))
+		}
+		if req.Mock.Priority != 0 {
+			sb.WriteString(fmt.Sprintf("  priority: %d\n", req.Mock.Priority))
+		}
+		if req.Mock.Paginate != "" {
+			sb.WriteString(fmt.Sprintf("  paginate: %s\n", req.Mock.Paginate))
+		}
+		if req.Mock.PageSize != 0 {
+			sb.WriteString(fmt.Sprintf("  pageSize: %d\n", req.Mock.PageSize))
+		}
+		if req.Mock.PageFormat != "" {
+			sb.WriteString(fmt.Sprintf("  pageFormat: %s\n", req.Mock.PageFormat))
+		}
+		if req.Mock.Filter != nil {
+			sb.WriteString(fmt.Sprintf("  filter: %t\n", *req.Mock.Filter))
+		}
+		if req.Mock.Sort != nil {
+			sb.WriteString(fmt.Sprintf("  sort: %t\n", *req.Mock.Sort))
+		}
+		if req.Mock.RateLimit != "" {
+			sb.WriteString(fmt.Sprintf("  rateLimit: %s\n", req.Mock.RateLimit))
+		}
+		if req.Mock.RateLimitKey != "" {
+			sb.WriteString(fmt.Sprintf("  rateLimitKey: %s\n", req.Mock.RateLimitKey))
+		}
+		if req.Mock.RateLimitAlgorithm != "" {
+			sb.WriteString(fmt.Sprintf("  rateLimitAlgorithm: %s\n", req.Mock.RateLimitAlgorithm))
+		}
+		sb.WriteString("}\n\n")
+	}
+
+	// Example blocks, one per saved response
+	for i := range req.Examples {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		s.writeExample(&sb, &req.Examples[i])
+	}
+
+	return sb.String()
+}
+
+// writeExample writes a single example block
+func (s *Serializer) writeExample(sb *strings.Builder, example *ExampleBlock) {
 	sb.WriteString("example {\n")
-	sb.WriteString(fmt.Sprintf("  name: %s\n", req.Example.Name))
-	if req.Example.Description != "" {
-		sb.WriteString(fmt.Sprintf("  description: %s\n", req.Example.Description))
+	sb.WriteString(fmt.Sprintf("  name: %s\n", example.Name))
+	if example.Description != "" {
+		sb.WriteString(fmt.Sprintf("  description: %s\n", example.Description))
+	}
+	if example.Scenario != "" {
+		sb.WriteString(fmt.Sprintf("  scenario: %s\n", example.Scenario))
+	}
+	if example.Delay != "" {
+		sb.WriteString(fmt.Sprintf("  delay: %s\n", example.Delay))
 	}
 	sb.WriteString("\n")
 
+	// Match block
+	if len(example.Match) > 0 {
+		sb.WriteString("  match: {\n")
+		for _, rule := range example.Match {
+			sb.WriteString(fmt.Sprintf("    %s\n", rule))
+		}
+		sb.WriteString("  }\n\n")
+	}
+
+	// Fault block
+	if !example.Fault.IsZero() {
+		s.writeFault(sb, example.Fault)
+	}
+
 	// Request block
 	sb.WriteString("  request: {\n")
-	sb.WriteString(fmt.Sprintf("    url: %s\n", req.Example.Request.URL))
-	sb.WriteString(fmt.Sprintf("    method: %s\n", req.Example.Request.Method))
-	sb.WriteString(fmt.Sprintf("    mode: %s\n", req.Example.Request.Mode))
+	sb.WriteString(fmt.Sprintf("    url: %s\n", example.Request.URL))
+	sb.WriteString(fmt.Sprintf("    method: %s\n", example.Request.Method))
+	sb.WriteString(fmt.Sprintf("    mode: %s\n", example.Request.Mode))
 	sb.WriteString("  }\n\n")
 
 	// Response block
 	sb.WriteString("  response: {\n")
 
 	// Headers
-	if len(req.Example.Response.Headers) > 0 {
+	if len(example.Response.Headers) > 0 {
 		sb.WriteString("    headers: {\n")
-		for key, value := range req.Example.Response.Headers {
+		for key, value := range example.Response.Headers {
 			sb.WriteString(fmt.Sprintf("      %s: %s\n", key, value))
 		}
 		sb.WriteString("    }\n\n")
 	}
 
-	// Status
-	sb.WriteString("    status: {\n")
-	sb.WriteString(fmt.Sprintf("      code: %d\n", req.Example.Response.Status.Code))
-	sb.WriteString(fmt.Sprintf("      text: %s\n", req.Example.Response.Status.Text))
-	sb.WriteString("    }\n\n")
+	// Status (an unset code means 200 and is left out)
+	if example.Response.Status.Code != 0 || example.Response.Status.Text != "" {
+		sb.WriteString("    status: {\n")
+		if example.Response.Status.Code != 0 {
+			sb.WriteString(fmt.Sprintf("      code: %d\n", example.Response.Status.Code))
+		}
+		sb.WriteString(fmt.Sprintf("      text: %s\n", example.Response.Status.Text))
+		sb.WriteString("    }\n\n")
+	}
 
 	// Body
 	sb.WriteString("    body: {\n")
-	sb.WriteString(fmt.Sprintf("      type: %s\n", req.Example.Response.Body.Type))
-	sb.WriteString("      content: '''\n")
-	sb.WriteString(req.Example.Response.Body.Content)
-	sb.WriteString("\n      '''\n")
+	sb.WriteString(fmt.Sprintf("      type: %s\n", example.Response.Body.Type))
+	if example.Response.Body.File != "" {
+		sb.WriteString(fmt.Sprintf("      file: %s\n", example.Response.Body.File))
+	}
+	if example.Response.Body.File == "" || example.Response.Body.Content != "" {
+		sb.WriteString("      content: '''\n")
+		sb.WriteString(indent(example.Response.Body.Content, "      "))
+		sb.WriteString("\n      '''\n")
+	}
 	sb.WriteString("    }\n")
 
 	sb.WriteString("  }\n")
 	sb.WriteString("}\n")
+}
 
-	return sb.String()
+// writeFault writes the fault block of an example
+func (s *Serializer) writeFault(sb *strings.Builder, fault FaultSettings) {
+	rate := func(r float64) string {
+		return strconv.FormatFloat(r, 'f', -1, 64) + "%"
+	}
+
+	sb.WriteString("  fault: {\n")
+	if fault.ErrorRate > 0 {
+		sb.WriteString(fmt.Sprintf("    error: %s", rate(fault.ErrorRate)))
+		if fault.ErrorStatus != 0 {
+			sb.WriteString(fmt.Sprintf(" %d", fault.ErrorStatus))
+		}
+		sb.WriteString("\n")
+	}
+	if fault.DropRate > 0 {
+		sb.WriteString(fmt.Sprintf("    drop: %s\n", rate(fault.DropRate)))
+	}
+	if fault.StallRate > 0 {
+		sb.WriteString(fmt.Sprintf("    stall: %s\n", rate(fault.StallRate)))
+	}
+	if fault.TruncateRate > 0 {
+		sb.WriteString(fmt.Sprintf("    truncate: %s\n", rate(fault.TruncateRate)))
+	}
+	sb.WriteString("  }\n\n")
+}
+
+// indent prefixes every non-empty line of text with the given indentation
+func indent(text, prefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
 }
@@ -0,0 +1,228 @@
+package brunoformat
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSerializeRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{
+			name: "minimal request",
+			src: `meta {
+  name: Health
+  type: http
+  seq: 1
+}
+
+get {
+  url: {{baseUrl}}/health
+}
+`,
+		},
+		{
+			name: "headers, query and body",
+			src: `meta {
+  name: Create user
+  type: http
+  seq: 3
+}
+
+post {
+  url: {{baseUrl}}/users
+}
+
+headers {
+  Content-Type: application/json
+  X-Tenant: acme
+}
+
+params:query {
+  dryRun: true
+}
+
+body:json {
+  {
+    "name": "Ada"
+  }
+}
+
+example {
+  name: Created
+  description: New user
+
+  request: {
+    url: /users
+    method: POST
+    mode: json
+  }
+
+  response: {
+    headers: {
+      content-type: application/json
+      location: /users/1
+    }
+
+    status: {
+      code: 201
+      text: Created
+    }
+
+    body: {
+      type: json
+      content: '''
+        {
+          "id": 1,
+          "name": "{{request.body.name}}"
+        }
+      '''
+    }
+  }
+}
+`,
+		},
+		{
+			name: "match, fault and scenario",
+			src: `meta {
+  name: Get order
+  type: http
+  seq: 1
+}
+
+get {
+  url: {{baseUrl}}/orders/:id
+}
+
+example {
+  name: Shipped
+  scenario: shipping
+  delay: 100ms-300ms
+
+  match: {
+    query.include: in items, totals
+    headers.x-version: gte 2
+    body.order.id: isDefined
+  }
+
+  fault: {
+    error: 12.5% 503
+    drop: 5%
+  }
+
+  response: {
+    body: {
+      type: text
+      content: '''
+        shipped
+      '''
+    }
+  }
+}
+
+example {
+  name: Fixture
+
+  response: {
+    body: {
+      type: binary
+      file: fixtures/order.pdf
+    }
+  }
+}
+`,
+		},
+		{
+			name: "mock settings",
+			src: `meta {
+  name: List jobs
+  type: http
+  seq: 1
+}
+
+get {
+  url: {{baseUrl}}/jobs
+}
+
+mock {
+  sequence: first, second
+  sequenceMode: repeat
+  delay: normal(300ms, 50ms)
+  proxy: false
+  priority: 10
+  paginate: cursor
+  pageSize: 25
+  pageFormat: envelope
+  filter: true
+  sort: false
+  rateLimit: 10/1m
+  rateLimitKey: header:X-Api-Key
+  rateLimitAlgorithm: token-bucket
+}
+
+example {
+  name: first
+}
+
+example {
+  name: second
+
+  response: {
+    body: {
+      type: sse
+      content: '''
+        data: {"step": 1}
+
+        delay: 1s
+        data: {"step": 2}
+      '''
+    }
+  }
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := decode(t, tt.src)
+			serialized := NewSerializer().Serialize(original)
+			reparsed := decode(t, serialized)
+
+			if !reflect.DeepEqual(withoutPositions(reparsed), withoutPositions(original)) {
+				t.Errorf("round trip changed the request\noriginal: %+v\nreparsed: %+v\nserialized:\n%s", original, reparsed, serialized)
+			}
+		})
+	}
+}
+
+// decode parses and decodes .bru source, failing the test on error
+func decode(t *testing.T, src string) *BrunoRequest {
+	t.Helper()
+	file, err := Parse("test.bru", src)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	req, err := DecodeRequest(file)
+	if err != nil {
+		t.Fatalf("DecodeRequest: %v", err)
+	}
+	return req
+}
+
+// withoutPositions clears the source positions of match rules, which depend
+// on the layout rather than the content of a file
+func withoutPositions(req *BrunoRequest) *BrunoRequest {
+	copied := *req
+	copied.Examples = make([]ExampleBlock, len(req.Examples))
+	for i, example := range req.Examples {
+		example.Match = append([]MatchRule(nil), example.Match...)
+		for j := range example.Match {
+			example.Match[j].Pos = Position{}
+		}
+		copied.Examples[i] = example
+	}
+	return &copied
+}
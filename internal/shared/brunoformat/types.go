@@ -1,5 +1,7 @@
 package brunoformat
 
+import "strings"
+
 // BrunoRequest represents a parsed .bru file
 type BrunoRequest struct {
 	FilePath    string
@@ -9,7 +11,136 @@ type BrunoRequest struct {
 	Headers     map[string]string
 	QueryParams map[string]string
 	Body        string
-	Example     ExampleBlock
+	Mock        MockSettings   // mock server behaviour from the optional mock block
+	FolderMock  MockSettings   // settings inherited from folder.bru files in enclosing directories
+	Examples    []ExampleBlock // at least one; the first is the default response
+}
+
+// Sequence modes control what happens after the last example of a sequence
+const (
+	SequenceStick  = "stick"  // keep serving the last example
+	SequenceRepeat = "repeat" // start over from the first example
+)
+
+// Pagination styles select the query parameters that page array responses
+const (
+	PaginatePage   = "page"   // ?page=2&limit=10, pages counted from 1
+	PaginateOffset = "offset" // ?offset=10&limit=10
+	PaginateCursor = "cursor" // ?cursor=...&limit=10, with an opaque cursor
+)
+
+// Page formats control how a page and its position are returned
+const (
+	PageFormatHeaders  = "headers"  // Link and X-Total-Count headers around the bare array
+	PageFormatEnvelope = "envelope" // {"data": [...], "pagination": {...}}
+)
+
+// MockSettings holds mock server settings from a mock block, either in a
+// request file or in a folder.bru file
+type MockSettings struct {
+	Sequence           []string // example names served one per call, in order
+	SequenceMode       string   // SequenceStick (default) or SequenceRepeat
+	Delay              string   // latency spec, e.g. "200ms", "100ms-500ms" or "normal(300ms, 50ms)"
+	Proxy              *bool    // true forwards the route to the upstream; false opts out of a folder's proxy
+	Priority           int      // the highest priority wins when several files declare the same route
+	Paginate           string   // PaginatePage, PaginateOffset or PaginateCursor; empty disables pagination
+	PageSize           int      // items per page unless the request asks for another limit
+	PageFormat         string   // PageFormatHeaders (default) or PageFormatEnvelope
+	Filter             *bool    // true filters array bodies by query parameters like ?status=active
+	Sort               *bool    // true sorts array bodies by ?sort=field&order=desc
+	RateLimit          string   // requests allowed per window, e.g. "10/1m"; empty disables rate limiting
+	RateLimitKey       string   // what requests are counted by: ip (default), auth or header:<name>
+	RateLimitAlgorithm string   // fixed-window (default) or token-bucket
+	RateLimitFolder    string   // folder whose rateLimit applies, shared by its requests; set when loading, not parsed
+}
+
+// Inherit fills settings that are unset with those of an enclosing folder
+func (m MockSettings) Inherit(parent MockSettings) MockSettings {
+	if m.Delay == "" {
+		m.Delay = parent.Delay
+	}
+	if m.Proxy == nil {
+		m.Proxy = parent.Proxy
+	}
+	if m.Priority == 0 {
+		m.Priority = parent.Priority
+	}
+	if m.Paginate == "" {
+		m.Paginate = parent.Paginate
+	}
+	if m.PageSize == 0 {
+		m.PageSize = parent.PageSize
+	}
+	if m.PageFormat == "" {
+		m.PageFormat = parent.PageFormat
+	}
+	if m.Filter == nil {
+		m.Filter = parent.Filter
+	}
+	if m.Sort == nil {
+		m.Sort = parent.Sort
+	}
+	if m.RateLimit == "" {
+		m.RateLimit = parent.RateLimit
+		m.RateLimitFolder = parent.RateLimitFolder
+	}
+	if m.RateLimitKey == "" {
+		m.RateLimitKey = parent.RateLimitKey
+	}
+	if m.RateLimitAlgorithm == "" {
+		m.RateLimitAlgorithm = parent.RateLimitAlgorithm
+	}
+	return m
+}
+
+// Proxied reports whether the settings force proxying to the upstream
+func (m MockSettings) Proxied() bool {
+	return m.Proxy != nil && *m.Proxy
+}
+
+// Filtered reports whether array bodies are filtered by query parameters
+func (m MockSettings) Filtered() bool {
+	return m.Filter != nil && *m.Filter
+}
+
+// Sorted reports whether array bodies are sorted by query parameters
+func (m MockSettings) Sorted() bool {
+	return m.Sort != nil && *m.Sort
+}
+
+// FolderSettings represents a parsed folder.bru file; its mock settings apply
+// to every request in the folder and its subfolders
+type FolderSettings struct {
+	FilePath string
+	Meta     MetaBlock
+	Mock     MockSettings
+}
+
+// DefaultExample returns the first example, which is served unless another is selected
+func (r *BrunoRequest) DefaultExample() *ExampleBlock {
+	if len(r.Examples) == 0 {
+		return nil
+	}
+	return &r.Examples[0]
+}
+
+// FindExample returns the example with the given name (case-insensitive), or nil
+func (r *BrunoRequest) FindExample(name string) *ExampleBlock {
+	for i := range r.Examples {
+		if strings.EqualFold(r.Examples[i].Name, name) {
+			return &r.Examples[i]
+		}
+	}
+	return nil
+}
+
+// ExampleNames returns the names of all examples in declaration order
+func (r *BrunoRequest) ExampleNames() []string {
+	names := make([]string, 0, len(r.Examples))
+	for _, example := range r.Examples {
+		names = append(names, example.Name)
+	}
+	return names
 }
 
 // MetaBlock contains metadata
@@ -23,10 +154,61 @@ type MetaBlock struct {
 type ExampleBlock struct {
 	Name        string
 	Description string
+	Scenario    string // only served while this named scenario is active
+	Delay       string // latency spec overriding the request and folder delay
+	Fault       FaultSettings
+	Match       []MatchRule // all must hold for the example to be served; empty means fallback
 	Request     ExampleRequest
 	Response    ExampleResponse
 }
 
+// Match rule sources
+const (
+	MatchQuery   = "query"
+	MatchHeaders = "headers"
+	MatchBody    = "body"
+)
+
+// MatchOperators lists the operators accepted in match rules, following Bruno's assert syntax
+var MatchOperators = []string{
+	"eq", "neq", "in", "notIn", "contains", "startsWith", "endsWith",
+	"matches", "notMatches", "gt", "gte", "lt", "lte", "isDefined", "isUndefined",
+}
+
+// MatchRule is a single condition from an example's match block,
+// e.g. "query.role: eq admin" or "body.user.name: matches ^a"
+type MatchRule struct {
+	Source   string // MatchQuery, MatchHeaders or MatchBody
+	Key      string // query parameter, header name or dot-notation body path
+	Operator string
+	Value    string
+	Pos      Position
+}
+
+// String formats the rule the way it is written in a .bru file
+func (m MatchRule) String() string {
+	rule := m.Source + "." + m.Key + ": " + m.Operator
+	if m.Value != "" {
+		rule += " " + m.Value
+	}
+	return rule
+}
+
+// FaultSettings configures failures injected when an example is served.
+// Rates are percentages of requests; together they may not exceed 100.
+type FaultSettings struct {
+	ErrorRate    float64 // respond with ErrorStatus instead of the example
+	ErrorStatus  int     // defaults to 500
+	DropRate     float64 // close the connection without a response
+	StallRate    float64 // send the headers, then never send the body
+	TruncateRate float64 // close the connection halfway through the body
+}
+
+// IsZero reports whether no fault is configured
+func (f FaultSettings) IsZero() bool {
+	return f.ErrorRate == 0 && f.DropRate == 0 && f.StallRate == 0 && f.TruncateRate == 0
+}
+
 // ExampleRequest contains request details in the example block
 type ExampleRequest struct {
 	URL    string
@@ -47,10 +229,42 @@ type ExampleStatus struct {
 	Text string
 }
 
+// Example body types
+const (
+	BodyJSON   = "json"
+	BodyText   = "text"
+	BodyXML    = "xml"
+	BodyHTML   = "html"
+	BodyBase64 = "base64" // content is base64-encoded binary data
+	BodyBinary = "binary" // alias of BodyBase64
+	BodySSE    = "sse"    // content is server-sent events, flushed one at a time
+	BodyStream = "stream" // content is chunks sent with chunked transfer encoding
+)
+
+// BodyTypes lists the accepted example body types
+var BodyTypes = []string{BodyJSON, BodyText, BodyXML, BodyHTML, BodyBase64, BodyBinary, BodySSE, BodyStream}
+
 // ExampleBody contains response body information
 type ExampleBody struct {
-	Type    string
+	Type    string // one of BodyTypes; empty means json
 	Content string
+	File    string // fixture served instead of Content, relative to the .bru file
+}
+
+// IsJSON reports whether the body is JSON, the default type
+func (b ExampleBody) IsJSON() bool {
+	return b.Type == "" || b.Type == BodyJSON
+}
+
+// IsStream reports whether the content is a sequence of events or chunks
+// sent one after another
+func (b ExampleBody) IsStream() bool {
+	return b.Type == BodySSE || b.Type == BodyStream
+}
+
+// IsBinary reports whether the content is base64-encoded binary data
+func (b ExampleBody) IsBinary() bool {
+	return b.Type == BodyBase64 || b.Type == BodyBinary
 }
 
 // NewDefaultExampleBlock creates a default example block for requests without one
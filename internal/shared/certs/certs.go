@@ -0,0 +1,232 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// File names inside the certificate directory
+const (
+	CAFile   = "ca.pem"
+	caKey    = "ca-key.pem"
+	leafFile = "cert.pem"
+	leafKey  = "key.pem"
+)
+
+const (
+	caValidity   = 10 * 365 * 24 * time.Hour
+	leafValidity = 365 * 24 * time.Hour
+	// renewBefore regenerates leaf certificates this long before they expire
+	renewBefore = 30 * 24 * time.Hour
+)
+
+// DefaultHosts are covered by generated certificates when no hosts are configured
+var DefaultHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// Options selects the certificate to serve
+type Options struct {
+	// CertFile and KeyFile are used as is when both are set
+	CertFile string
+	KeyFile  string
+	// Dir holds the generated CA and leaf certificate
+	Dir string
+	// Hosts are the DNS names and IP addresses the leaf certificate is valid for
+	Hosts []string
+}
+
+// DefaultDir returns the directory for generated certificates: the user's
+// cache directory, or a .certs directory in fallback if there is none
+func DefaultDir(fallback string) string {
+	if cache, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(cache, "bruno-mock-server", "certs")
+	}
+	return filepath.Join(fallback, ".certs")
+}
+
+// TLSConfig returns a TLS configuration serving the configured certificate.
+// Without cert and key files, a local CA and a leaf certificate for Hosts
+// are generated in Dir on first use and reused afterwards. The leaf is
+// regenerated when the hosts change or it is about to expire; the CA is
+// kept, so it only has to be trusted once.
+func TLSConfig(opts Options) (*tls.Config, error) {
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return nil, fmt.Errorf("both a certificate and a key file are required")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate: %w", err)
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+	}
+
+	hosts := opts.Hosts
+	if len(hosts) == 0 {
+		hosts = DefaultHosts
+	}
+	if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create certificate directory: %w", err)
+	}
+
+	ca, caPriv, err := loadOrCreateCA(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := loadOrCreateLeaf(opts.Dir, hosts, ca, caPriv)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+}
+
+// loadOrCreateCA loads the local CA from dir, generating it if it does not exist
+func loadOrCreateCA(dir string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPath, keyPath := filepath.Join(dir, CAFile), filepath.Join(dir, caKey)
+
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err == nil {
+		priv, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, nil, fmt.Errorf("%s: unsupported CA key type", keyPath)
+		}
+		ca, err := x509.ParseCertificate(pair.Certificate[0])
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", certPath, err)
+		}
+		return ca, priv, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("failed to load local CA: %w", err)
+	}
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber(),
+		Subject:               pkix.Name{Organization: []string{"Bruno Mock Server"}, CommonName: "Bruno Mock Server Local CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create local CA: %w", err)
+	}
+	if err := writePair(certPath, keyPath, der, priv); err != nil {
+		return nil, nil, err
+	}
+	log.Printf("Generated local CA %s; trust it to avoid certificate warnings", certPath)
+
+	ca, err := x509.ParseCertificate(der)
+	return ca, priv, err
+}
+
+// loadOrCreateLeaf loads the leaf certificate from dir, generating a new one
+// if it is missing, does not cover hosts or is about to expire
+func loadOrCreateLeaf(dir string, hosts []string, ca *x509.Certificate, caPriv *ecdsa.PrivateKey) (tls.Certificate, error) {
+	certPath, keyPath := filepath.Join(dir, leafFile), filepath.Join(dir, leafKey)
+
+	if pair, err := tls.LoadX509KeyPair(certPath, keyPath); err == nil {
+		leaf, err := x509.ParseCertificate(pair.Certificate[0])
+		if err == nil && leaf.CheckSignatureFrom(ca) == nil &&
+			time.Until(leaf.NotAfter) > renewBefore && covers(leaf, hosts) {
+			return pair, nil
+		}
+	}
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serialNumber(),
+		Subject:      pkix.Name{Organization: []string{"Bruno Mock Server"}, CommonName: hosts[0]},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(leafValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &priv.PublicKey, caPriv)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	if err := writePair(certPath, keyPath, der, priv); err != nil {
+		return tls.Certificate{}, err
+	}
+	log.Printf("Generated certificate %s for %v", certPath, hosts)
+
+	return tls.LoadX509KeyPair(certPath, keyPath)
+}
+
+// covers reports whether a certificate is valid for exactly the given hosts
+func covers(cert *x509.Certificate, hosts []string) bool {
+	var names []string
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	names = append(names, cert.DNSNames...)
+
+	want := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			host = ip.String()
+		}
+		want = append(want, host)
+	}
+
+	slices.Sort(names)
+	slices.Sort(want)
+	return slices.Equal(names, slices.Compact(want))
+}
+
+// writePair writes a certificate and its private key as PEM files
+func writePair(certPath, keyPath string, der []byte, priv *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		return fmt.Errorf("failed to write certificate: %w", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+	return nil
+}
+
+// serialNumber returns a random certificate serial number
+func serialNumber() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return big.NewInt(time.Now().UnixNano())
+	}
+	return serial
+}
@@ -0,0 +1,70 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Lookup resolves a dot-notation path such as "user.tags[0].name" or
+// "items.2.id" against a value decoded by encoding/json.
+// It reports false when any segment of the path does not exist.
+func Lookup(data interface{}, path string) (interface{}, bool) {
+	current := data
+	for _, segment := range Split(path) {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// Split breaks a path into its segments, turning "a[0].b" into ["a", "0", "b"]
+func Split(path string) []string {
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+
+	var segments []string
+	for _, segment := range strings.Split(path, ".") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// Stringify formats a decoded JSON value for comparison with text:
+// strings are returned as-is, numbers without trailing zeros, and
+// objects or arrays as compact JSON
+func Stringify(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCORSMethods are allowed when CORSOptions.AllowedMethods is empty
+var DefaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
+
+// CORSOptions configures cross-origin resource sharing
+type CORSOptions struct {
+	// AllowedOrigins lists origins such as https://app.example.com; entries
+	// may contain wildcards (https://*.example.com) and "*" allows any origin.
+	// Empty allows any origin, like "*".
+	AllowedOrigins []string
+	// AllowedMethods answers preflight requests; empty uses DefaultCORSMethods
+	AllowedMethods []string
+	// AllowedHeaders answers preflight requests; empty allows the headers the browser asks for
+	AllowedHeaders []string
+	// ExposedHeaders are readable by scripts; empty exposes every header of the response
+	ExposedHeaders []string
+	// AllowCredentials lets browsers send cookies and authorization headers,
+	// but only from origins listed in AllowedOrigins other than "*"
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+// CORS answers preflight requests and adds Access-Control-* headers to
+// responses for allowed origins. Headers set by a handler take precedence,
+// so a route can override the CORS policy for its own responses. Requests
+// whose path starts with one of the exempt prefixes get no CORS handling.
+func CORS(opts CORSOptions, exempt ...string) func(http.Handler) http.Handler {
+	methods := opts.AllowedMethods
+	if len(methods) == 0 {
+		methods = DefaultCORSMethods
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" || hasAnyPrefix(r.URL.Path, exempt) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			allowed, credentials, ok := opts.allowOrigin(origin)
+			if !ok {
+				if preflight {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h.Set("Access-Control-Allow-Origin", allowed)
+			if credentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if preflight {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+				h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+				if len(opts.AllowedHeaders) > 0 {
+					h.Set("Access-Control-Allow-Headers", strings.Join(opts.AllowedHeaders, ", "))
+				} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+					h.Set("Access-Control-Allow-Headers", requested)
+				}
+				if opts.MaxAge > 0 {
+					h.Set("Access-Control-Max-Age", strconv.Itoa(int(opts.MaxAge.Seconds())))
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if len(opts.ExposedHeaders) > 0 {
+				h.Set("Access-Control-Expose-Headers", strings.Join(opts.ExposedHeaders, ", "))
+			}
+			next.ServeHTTP(&corsWriter{ResponseWriter: w}, r)
+		})
+	}
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for an origin
+// and whether credentials are allowed, reporting false if the origin is not
+// allowed. Credentials are only allowed for origins that are listed: an
+// origin allowed through "*" gets "*", which browsers never send cookies to.
+func (opts CORSOptions) allowOrigin(origin string) (string, bool, bool) {
+	anyOrigin := len(opts.AllowedOrigins) == 0
+	for _, pattern := range opts.AllowedOrigins {
+		if pattern == "*" {
+			anyOrigin = true
+			continue
+		}
+		if strings.EqualFold(pattern, origin) {
+			return origin, opts.AllowCredentials, true
+		}
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(origin)); ok {
+			return origin, opts.AllowCredentials, true
+		}
+	}
+	if anyOrigin {
+		return "*", false, true
+	}
+	return "", false, false
+}
+
+// corsWriter finalizes the CORS headers when the response is written:
+// a handler that added its own Access-Control-* header (e.g. a proxied
+// upstream) wins over the middleware's, and without configured exposed
+// headers every response header is exposed by name, since "*" does not
+// work for credentialed requests
+type corsWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (cw *corsWriter) WriteHeader(code int) {
+	if !cw.wroteHeader && code >= http.StatusOK {
+		cw.wroteHeader = true
+		cw.finalize()
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *corsWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (cw *corsWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// finalize keeps the last value of repeated Access-Control-* headers and
+// fills in Access-Control-Expose-Headers
+func (cw *corsWriter) finalize() {
+	h := cw.Header()
+	var names []string
+	for name, values := range h {
+		if strings.HasPrefix(name, "Access-Control-") {
+			if len(values) > 1 {
+				h[name] = values[len(values)-1:]
+			}
+			continue
+		}
+		if name != "Vary" {
+			names = append(names, name)
+		}
+	}
+
+	if h.Get("Access-Control-Expose-Headers") == "" && len(names) > 0 {
+		sort.Strings(names)
+		h.Set("Access-Control-Expose-Headers", strings.Join(names, ", "))
+	}
+}
+
+// hasAnyPrefix reports whether path starts with one of the prefixes
+func hasAnyPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSAllowOrigin(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        CORSOptions
+		origin      string
+		path        string
+		allowOrigin string
+		credentials string
+	}{
+		{name: "any origin", opts: CORSOptions{}, origin: "https://a.test", allowOrigin: "*"},
+		{name: "any origin never credentialed", opts: CORSOptions{AllowCredentials: true}, origin: "https://a.test", allowOrigin: "*"},
+		{name: "star never credentialed", opts: CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true}, origin: "https://a.test", allowOrigin: "*"},
+		{name: "listed origin", opts: CORSOptions{AllowedOrigins: []string{"https://a.test"}}, origin: "https://a.test", allowOrigin: "https://a.test"},
+		{name: "listed origin credentialed", opts: CORSOptions{AllowedOrigins: []string{"https://a.test"}, AllowCredentials: true}, origin: "https://a.test", allowOrigin: "https://a.test", credentials: "true"},
+		{name: "wildcard pattern", opts: CORSOptions{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true}, origin: "https://pr-1.example.com", allowOrigin: "https://pr-1.example.com", credentials: "true"},
+		{name: "listed beats star", opts: CORSOptions{AllowedOrigins: []string{"*", "https://a.test"}, AllowCredentials: true}, origin: "https://a.test", allowOrigin: "https://a.test", credentials: "true"},
+		{name: "unlisted origin", opts: CORSOptions{AllowedOrigins: []string{"https://a.test"}, AllowCredentials: true}, origin: "https://evil.test"},
+		{name: "exempt path", opts: CORSOptions{}, origin: "https://a.test", path: "/__admin/stubs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := CORS(tt.opts, "/__admin")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			path := tt.path
+			if path == "" {
+				path = "/items"
+			}
+			r := httptest.NewRequest("GET", path, nil)
+			r.Header.Set("Origin", tt.origin)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.allowOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.credentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.credentials)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anu-mdl/linker-bruno/internal/shared/response"
+)
+
+// Rate limit algorithms
+const (
+	FixedWindow = "fixed-window" // count requests per window, starting over when it ends
+	TokenBucket = "token-bucket" // refill the allowance steadily, allowing bursts up to the limit
+)
+
+// Rate limit keys select what requests are counted by; header:<name> counts
+// by the value of a request header such as an API key
+const (
+	RateLimitByIP   = "ip"   // the client IP address
+	RateLimitByAuth = "auth" // the Authorization header, i.e. the auth token
+)
+
+// GlobalRateLimitScope is the scope of the limit applied by LimitRate
+const GlobalRateLimitScope = "global"
+
+// RateLimit allows Requests per Window for each client
+type RateLimit struct {
+	Requests  int
+	Window    time.Duration
+	Algorithm string // FixedWindow or TokenBucket
+	Key       string // RateLimitByIP, RateLimitByAuth or header:<name>
+}
+
+// ParseRateLimit parses a limit like "10/1m", "100/s" or "5/10s" together
+// with what it counts requests by and its algorithm; empty values default
+// to RateLimitByIP and FixedWindow
+func ParseRateLimit(spec, key, algorithm string) (RateLimit, error) {
+	limit := RateLimit{Algorithm: algorithm, Key: key}
+	if limit.Algorithm == "" {
+		limit.Algorithm = FixedWindow
+	}
+	if limit.Key == "" {
+		limit.Key = RateLimitByIP
+	}
+
+	count, window, ok := strings.Cut(strings.TrimSpace(spec), "/")
+	n, err := strconv.Atoi(strings.TrimSpace(count))
+	if !ok || err != nil || n < 1 {
+		return limit, fmt.Errorf("invalid rate limit %q: expected requests per window, e.g. 10/1m", spec)
+	}
+	limit.Requests = n
+
+	// A bare unit like "s" means one of it
+	duration := strings.TrimSpace(window)
+	if duration != "" && !strings.ContainsAny(duration[:1], "0123456789") {
+		duration = "1" + duration
+	}
+	if limit.Window, err = time.ParseDuration(duration); err != nil || limit.Window <= 0 {
+		return limit, fmt.Errorf("invalid rate limit window %q: expected a duration, e.g. 1m or s", window)
+	}
+
+	if limit.Algorithm != FixedWindow && limit.Algorithm != TokenBucket {
+		return limit, fmt.Errorf("invalid rate limit algorithm %q: expected %s or %s", algorithm, FixedWindow, TokenBucket)
+	}
+	if name, ok := strings.CutPrefix(limit.Key, "header:"); ok {
+		if strings.TrimSpace(name) == "" {
+			return limit, fmt.Errorf("invalid rate limit key %q: expected a header name after header:", key)
+		}
+	} else if limit.Key != RateLimitByIP && limit.Key != RateLimitByAuth {
+		return limit, fmt.Errorf("invalid rate limit key %q: expected %s, %s or header:<name>", key, RateLimitByIP, RateLimitByAuth)
+	}
+	return limit, nil
+}
+
+// String returns the limit as in the configuration, e.g. 10/1m0s fixed-window by ip
+func (l RateLimit) String() string {
+	return fmt.Sprintf("%d/%s %s by %s", l.Requests, l.Window, l.Algorithm, l.Key)
+}
+
+// client returns what identifies the client of a request under the limit's
+// key, falling back to its IP address when the header is missing
+func (l RateLimit) client(r *http.Request) string {
+	var value string
+	switch {
+	case l.Key == RateLimitByAuth:
+		value = r.Header.Get("Authorization")
+	case strings.HasPrefix(l.Key, "header:"):
+		value = r.Header.Get(strings.TrimSpace(strings.TrimPrefix(l.Key, "header:")))
+	}
+	if value != "" {
+		return value
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// RateStatus is the outcome of counting a request against a limit
+type RateStatus struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Time     // when the full allowance is available again
+	RetryAfter time.Duration // until the next request is allowed, when it is not now
+}
+
+// SetHeaders sets the X-RateLimit-* headers, with the reset time in Unix
+// seconds, and Retry-After for rejected requests
+func (st RateStatus) SetHeaders(h http.Header) {
+	h.Set("X-RateLimit-Limit", strconv.Itoa(st.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(st.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(float64(st.Reset.UnixMilli())/1000)), 10))
+	if !st.Allowed {
+		h.Set("Retry-After", strconv.Itoa(st.RetryAfterSeconds()))
+	}
+}
+
+// RetryAfterSeconds returns RetryAfter rounded up to whole seconds, at least 1
+func (st RateStatus) RetryAfterSeconds() int {
+	return max(int(math.Ceil(st.RetryAfter.Seconds())), 1)
+}
+
+// RateLimiter counts requests per scope (e.g. a route or folder) and client.
+// It is safe for concurrent use.
+type RateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+// sweepInterval is how often Allow drops the buckets of idle clients
+const sweepInterval = time.Minute
+
+type bucketKey struct {
+	scope, client string
+}
+
+// bucket is the allowance of one client within a scope
+type bucket struct {
+	limit  RateLimit
+	count  int       // requests in the current window, for FixedWindow
+	tokens float64   // requests left, for TokenBucket
+	start  time.Time // start of the window, or the last refill for TokenBucket
+}
+
+// NewRateLimiter creates a rate limiter without any counted requests
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{buckets: make(map[bucketKey]*bucket)}
+}
+
+// Allow counts a request against a limit within a scope
+func (rl *RateLimiter) Allow(scope string, limit RateLimit, r *http.Request) RateStatus {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(rl.lastSweep) >= sweepInterval {
+		rl.sweep(now)
+	}
+
+	key := bucketKey{scope: scope, client: limit.client(r)}
+	b := rl.buckets[key]
+	// A changed limit, e.g. after a reload, starts afresh
+	if b == nil || b.limit != limit {
+		b = &bucket{limit: limit, tokens: float64(limit.Requests), start: now}
+		rl.buckets[key] = b
+	}
+	return b.take(now)
+}
+
+// sweep drops the buckets whose full allowance is available again, so that
+// clients which stopped making requests do not accumulate. A dropped bucket
+// is recreated full, so this does not change any outcome. mu must be held.
+func (rl *RateLimiter) sweep(now time.Time) {
+	for key, b := range rl.buckets {
+		if b.status(now).Remaining == b.limit.Requests {
+			delete(rl.buckets, key)
+		}
+	}
+	rl.lastSweep = now
+}
+
+// take counts a request made at now
+func (b *bucket) take(now time.Time) RateStatus {
+	st := b.status(now)
+	if st.Remaining == 0 {
+		return st
+	}
+
+	if b.limit.Algorithm == TokenBucket {
+		b.tokens--
+		st = b.status(now)
+	} else {
+		b.count++
+		st.Remaining--
+	}
+	st.Allowed = true
+	return st
+}
+
+// status returns the allowance at now without counting a request
+func (b *bucket) status(now time.Time) RateStatus {
+	limit := b.limit
+	st := RateStatus{Limit: limit.Requests}
+
+	if limit.Algorithm == TokenBucket {
+		rate := float64(limit.Requests) / float64(limit.Window)
+		b.tokens = min(b.tokens+float64(now.Sub(b.start))*rate, float64(limit.Requests))
+		b.start = now
+		st.Remaining = int(b.tokens)
+		st.Reset = now.Add(time.Duration((float64(limit.Requests) - b.tokens) / rate))
+		if st.Remaining == 0 {
+			st.RetryAfter = time.Duration((1 - b.tokens) / rate)
+		}
+		return st
+	}
+
+	if !now.Before(b.start.Add(limit.Window)) {
+		b.start, b.count = now, 0
+	}
+	st.Remaining = limit.Requests - b.count
+	st.Reset = b.start.Add(limit.Window)
+	if st.Remaining == 0 {
+		st.RetryAfter = st.Reset.Sub(now)
+	}
+	return st
+}
+
+// RateLimitBucket describes the allowance of one client within a scope
+type RateLimitBucket struct {
+	Scope     string    `json:"scope"`
+	Client    string    `json:"client"`
+	Limit     string    `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// Buckets returns the allowance of every client with requests still counted
+// against it, by scope and client; clients back at their full allowance may
+// have been dropped
+func (rl *RateLimiter) Buckets() []RateLimitBucket {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	buckets := make([]RateLimitBucket, 0, len(rl.buckets))
+	for key, b := range rl.buckets {
+		st := b.status(now)
+		buckets = append(buckets, RateLimitBucket{
+			Scope:     key.scope,
+			Client:    key.client,
+			Limit:     b.limit.String(),
+			Remaining: st.Remaining,
+			Reset:     st.Reset,
+		})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Scope != buckets[j].Scope {
+			return buckets[i].Scope < buckets[j].Scope
+		}
+		return buckets[i].Client < buckets[j].Client
+	})
+	return buckets
+}
+
+// Reset forgets all counted requests, restoring every client's full allowance
+func (rl *RateLimiter) Reset() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.buckets = make(map[bucketKey]*bucket)
+}
+
+// LimitRate rejects requests over a limit shared by all routes with 429 Too
+// Many Requests, and adds X-RateLimit-* headers to the responses of the
+// others. Requests whose path starts with one of the exempt prefixes are
+// neither counted nor limited.
+func LimitRate(limiter *RateLimiter, limit RateLimit, exempt ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if hasAnyPrefix(r.URL.Path, exempt) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			st := limiter.Allow(GlobalRateLimitScope, limit, r)
+			st.SetHeaders(w.Header())
+			if !st.Allowed {
+				WriteTooManyRequests(w, st)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WriteTooManyRequests writes the default 429 response for a rejected request
+func WriteTooManyRequests(w http.ResponseWriter, st RateStatus) {
+	response.WriteError(w, http.StatusTooManyRequests, "RATE_LIMITED",
+		fmt.Sprintf("rate limit of %d requests exceeded; retry in %d seconds", st.Limit, st.RetryAfterSeconds()))
+}
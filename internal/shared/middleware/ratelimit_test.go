@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		spec, key, algorithm string
+		want                 RateLimit
+		err                  string
+	}{
+		{spec: "10/1m", want: RateLimit{Requests: 10, Window: time.Minute, Algorithm: FixedWindow, Key: RateLimitByIP}},
+		{spec: "100/s", key: RateLimitByAuth, want: RateLimit{Requests: 100, Window: time.Second, Algorithm: FixedWindow, Key: RateLimitByAuth}},
+		{spec: " 5 / 10s ", algorithm: TokenBucket, want: RateLimit{Requests: 5, Window: 10 * time.Second, Algorithm: TokenBucket, Key: RateLimitByIP}},
+		{spec: "3/h", key: "header:X-Api-Key", want: RateLimit{Requests: 3, Window: time.Hour, Algorithm: FixedWindow, Key: "header:X-Api-Key"}},
+		{spec: "10", err: "expected requests per window"},
+		{spec: "0/1m", err: "expected requests per window"},
+		{spec: "ten/1m", err: "expected requests per window"},
+		{spec: "10/1xyz", err: `invalid rate limit window "1xyz"`},
+		{spec: "10/0s", err: "invalid rate limit window"},
+		{spec: "10/1m", algorithm: "leaky-bucket", err: `invalid rate limit algorithm "leaky-bucket"`},
+		{spec: "10/1m", key: "cookie", err: `invalid rate limit key "cookie"`},
+		{spec: "10/1m", key: "header: ", err: "expected a header name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec+" "+tt.key+" "+tt.algorithm, func(t *testing.T) {
+			got, err := ParseRateLimit(tt.spec, tt.key, tt.algorithm)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBucketTake(t *testing.T) {
+	type call struct {
+		at        time.Duration // since the first request
+		allowed   bool
+		remaining int
+		retry     time.Duration // RetryAfter of rejected requests
+	}
+	tests := []struct {
+		name      string
+		algorithm string
+		calls     []call
+	}{
+		{
+			name:      "fixed window",
+			algorithm: FixedWindow,
+			calls: []call{
+				{at: 0, allowed: true, remaining: 2},
+				{at: 10 * time.Second, allowed: true, remaining: 1},
+				{at: 20 * time.Second, allowed: true, remaining: 0},
+				{at: 45 * time.Second, allowed: false, remaining: 0, retry: 15 * time.Second},
+				{at: 60 * time.Second, allowed: true, remaining: 2},
+			},
+		},
+		{
+			name:      "token bucket",
+			algorithm: TokenBucket,
+			calls: []call{
+				{at: 0, allowed: true, remaining: 2},
+				{at: 0, allowed: true, remaining: 1},
+				{at: 0, allowed: true, remaining: 0},
+				{at: 10 * time.Second, allowed: false, remaining: 0, retry: 10 * time.Second},
+				{at: 20 * time.Second, allowed: true, remaining: 0},
+				{at: 60 * time.Second, allowed: true, remaining: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+			limit := RateLimit{Requests: 3, Window: time.Minute, Algorithm: tt.algorithm, Key: RateLimitByIP}
+			b := &bucket{limit: limit, tokens: 3, start: start}
+
+			for i, c := range tt.calls {
+				st := b.take(start.Add(c.at))
+				if st.Allowed != c.allowed || st.Remaining != c.remaining {
+					t.Errorf("call %d at %s: allowed %v, remaining %d; want %v, %d", i, c.at, st.Allowed, st.Remaining, c.allowed, c.remaining)
+				}
+				if !c.allowed && st.RetryAfter.Round(time.Millisecond) != c.retry {
+					t.Errorf("call %d at %s: retry after %s, want %s", i, c.at, st.RetryAfter, c.retry)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterClients(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		requests [][2]string // remote address and header value
+		allowed  []bool
+	}{
+		{
+			name:     "by ip",
+			key:      RateLimitByIP,
+			requests: [][2]string{{"10.0.0.1:1000", ""}, {"10.0.0.1:2000", ""}, {"10.0.0.2:1000", ""}},
+			allowed:  []bool{true, false, true},
+		},
+		{
+			name:     "by auth",
+			key:      RateLimitByAuth,
+			requests: [][2]string{{"10.0.0.1:1000", "Bearer a"}, {"10.0.0.1:1000", "Bearer b"}, {"10.0.0.2:1000", "Bearer a"}},
+			allowed:  []bool{true, true, false},
+		},
+		{
+			name:     "by header falling back to ip",
+			key:      "header:X-Api-Key",
+			requests: [][2]string{{"10.0.0.1:1000", "k1"}, {"10.0.0.1:1000", ""}, {"10.0.0.1:1000", ""}},
+			allowed:  []bool{true, true, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := NewRateLimiter()
+			limit := RateLimit{Requests: 1, Window: time.Minute, Algorithm: FixedWindow, Key: tt.key}
+
+			for i, req := range tt.requests {
+				r := httptest.NewRequest("GET", "/", nil)
+				r.RemoteAddr = req[0]
+				if req[1] != "" {
+					r.Header.Set("Authorization", req[1])
+					r.Header.Set("X-Api-Key", req[1])
+				}
+				if got := rl.Allow("route", limit, r).Allowed; got != tt.allowed[i] {
+					t.Errorf("request %d: allowed = %v, want %v", i, got, tt.allowed[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	limit := func(algorithm string) RateLimit {
+		return RateLimit{Requests: 2, Window: time.Minute, Algorithm: algorithm, Key: RateLimitByIP}
+	}
+
+	tests := []struct {
+		name   string
+		bucket *bucket
+		kept   bool
+	}{
+		{name: "fixed window in progress", bucket: &bucket{limit: limit(FixedWindow), count: 1, start: start.Add(-30 * time.Second)}, kept: true},
+		{name: "fixed window ended", bucket: &bucket{limit: limit(FixedWindow), count: 2, start: start.Add(-time.Minute)}, kept: false},
+		{name: "token bucket refilling", bucket: &bucket{limit: limit(TokenBucket), tokens: 0, start: start.Add(-30 * time.Second)}, kept: true},
+		{name: "token bucket full", bucket: &bucket{limit: limit(TokenBucket), tokens: 1, start: start.Add(-30 * time.Second)}, kept: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl := NewRateLimiter()
+			key := bucketKey{scope: "route", client: "10.0.0.1"}
+			rl.buckets[key] = tt.bucket
+
+			rl.sweep(start)
+			if _, kept := rl.buckets[key]; kept != tt.kept {
+				t.Errorf("kept = %v, want %v", kept, tt.kept)
+			}
+		})
+	}
+}
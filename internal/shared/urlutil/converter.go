@@ -1,10 +1,15 @@
 package urlutil
 
 import (
+	"net"
+	"net/url"
 	"regexp"
 	"strings"
 )
 
+// originPattern matches the scheme://host[:port] prefix of an absolute URL
+var originPattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://[^/?#]*`)
+
 // Converter handles URL pattern conversions and ID encoding/decoding
 type Converter struct{}
 
@@ -14,15 +19,13 @@ func NewConverter() *Converter {
 }
 
 // ConvertPattern converts a Bruno URL pattern to a chi route pattern
-// Replaces environment variables and converts :param to {param}
+// Replaces environment variables, drops the scheme and host and converts :param to {param}
 func (c *Converter) ConvertPattern(brunoURL string, envVars map[string]string) string {
-	path := brunoURL
+	path := substitute(brunoURL, envVars)
 
-	// Replace environment variables like {{baseUrl}}
-	for key, value := range envVars {
-		placeholder := "{{" + key + "}}"
-		path = strings.ReplaceAll(path, placeholder, value)
-	}
+	// Drop the scheme and host of an absolute URL like http://localhost:3000/users;
+	// see Host
+	path = originPattern.ReplaceAllString(path, "")
 
 	// Remove any remaining {{variable}} placeholders (like {{baseUrl}})
 	// by stripping them out entirely
@@ -45,6 +48,41 @@ func (c *Converter) ConvertPattern(brunoURL string, envVars map[string]string) s
 	return path
 }
 
+// Host returns the host name a Bruno URL points at once environment variables
+// are replaced, e.g. api.example.local for {{baseUrl}}/users with baseUrl set
+// to https://api.example.local:8443. It is lowercase and without port, and
+// empty for URLs without scheme and host.
+func (c *Converter) Host(brunoURL string, envVars map[string]string) string {
+	origin := originPattern.FindString(substitute(brunoURL, envVars))
+	if origin == "" {
+		return ""
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return ""
+	}
+	return NormalizeHost(u.Hostname())
+}
+
+// NormalizeHost lowercases a host name and drops its port and trailing dot,
+// so that Host headers compare equal to the hosts of Bruno URLs
+func NormalizeHost(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// substitute replaces environment variables like {{baseUrl}} in a Bruno URL
+func substitute(brunoURL string, envVars map[string]string) string {
+	for key, value := range envVars {
+		placeholder := "{{" + key + "}}"
+		brunoURL = strings.ReplaceAll(brunoURL, placeholder, value)
+	}
+	return brunoURL
+}
+
 // EncodeID generates a unique URL-safe identifier from file path
 // Uses multi-character replacements to avoid conflicts
 func (c *Converter) EncodeID(filePath string) string {